@@ -10,9 +10,11 @@ import (
 	"api-gateway/internal/app"
 	httpapp "api-gateway/internal/app/http"
 	"api-gateway/internal/config"
+	"api-gateway/internal/tracing"
 
 	cfgtools "github.com/hesoyamTM/nbf-auth/pkg/config"
 	"github.com/hesoyamTM/nbf-auth/pkg/logger"
+	"go.uber.org/zap"
 )
 
 // @title nbf API
@@ -22,6 +24,10 @@ import (
 // @BasePath /api/v1
 func main() {
 	cfg := cfgtools.MustParseConfig[config.Config]()
+	if err := cfg.Validate(); err != nil {
+		panic(err)
+	}
+
 	ctx, err := logger.SetupLogger(context.Background(), cfg.Env)
 	if err != nil {
 		panic(err)
@@ -34,6 +40,11 @@ func main() {
 
 	log.Debug("Logger is working")
 
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		panic(err)
+	}
+
 	Clients := httpapp.Clients{
 		AuthService_Addr:         cfg.GRPC_Clients.AuthService,
 		UserService_Addr:         cfg.GRPC_Clients.UserService,
@@ -52,7 +63,15 @@ func main() {
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 	<-stop
 
-	application.HttpApp.MustStop()
+	log.Info("Stopping server")
+
+	if err := application.HttpApp.Stop(ctx); err != nil {
+		log.Error("failed to stop server gracefully", zap.Error(err))
+	}
+
+	if err := shutdownTracing(ctx); err != nil {
+		log.Error("failed to flush traces", zap.Error(err))
+	}
 
 	log.Info("Server is stopped")
 }
@@ -2,34 +2,59 @@ package httpapp
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"time"
 
 	"api-gateway/internal/clients/auth"
 	"api-gateway/internal/clients/chat"
+	"api-gateway/internal/clients/interceptors"
 	"api-gateway/internal/clients/matcher"
 	"api-gateway/internal/clients/notification"
 	s3 "api-gateway/internal/clients/storage"
 	"api-gateway/internal/clients/user"
 	"api-gateway/internal/config"
+	"api-gateway/internal/jobs"
+	"api-gateway/internal/metrics"
+	"api-gateway/internal/ports/authn"
+	"api-gateway/internal/ports/cookies"
+	"api-gateway/internal/ports/deletion"
+	"api-gateway/internal/ports/export"
 	"api-gateway/internal/ports/handlers/auth_handler"
 	"api-gateway/internal/ports/handlers/chat_handler"
+	"api-gateway/internal/ports/handlers/health_handler"
 	"api-gateway/internal/ports/handlers/matcher_handler"
 	"api-gateway/internal/ports/handlers/notification_handler"
 	"api-gateway/internal/ports/handlers/user_handler"
 	"api-gateway/internal/ports/middlewares"
+	"api-gateway/internal/ports/photos"
+	"api-gateway/internal/ports/streams"
+	"api-gateway/internal/requestinfo"
+	"api-gateway/internal/tlsreload"
+	"api-gateway/internal/tracing"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
-	authMid "github.com/hesoyamTM/nbf-auth/pkg/auth"
-	decodeKeys "github.com/hesoyamTM/nbf-auth/pkg/config"
 	"github.com/hesoyamTM/nbf-auth/pkg/logger"
 	httpSwagger "github.com/swaggo/http-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 type App struct {
-	router     *chi.Mux
-	httpServer *http.Server
+	router          *chi.Mux
+	httpServer      *http.Server
+	redirectServer  *http.Server
+	health          *health_handler.HealthHandler
+	streams         *streams.Registry
+	conns           []*grpc.ClientConn
+	shutdownTimeout time.Duration
 }
 
 type Clients struct {
@@ -51,42 +76,73 @@ func New(ctx context.Context, cfg *config.Config, clients Clients) *App {
 
 	// New Clients
 
-	AuthClient, err := auth.New(ctx, clients.AuthService_Addr)
+	AuthClient, err := auth.New(ctx, clients.AuthService_Addr, dialOptions(ctx, "auth", cfg.GRPC_Clients.Auth)...)
 	if err != nil {
 		log.Error("failed to connect auth client", zap.Error(err))
 	}
 
-	UserClient, err := user.New(ctx, clients.UserService_Addr)
+	UserClient, err := user.New(ctx, clients.UserService_Addr, dialOptions(ctx, "user", cfg.GRPC_Clients.User)...)
 	if err != nil {
 		log.Error("failed to connect user client", zap.Error(err))
 	}
 
-	MatcherClient, err := matcher.New(ctx, clients.MatcherService_Addr)
+	MatcherClient, err := matcher.New(ctx, clients.MatcherService_Addr, dialOptions(ctx, "matcher", cfg.GRPC_Clients.Matcher)...)
 	if err != nil {
 		log.Error("failed to connect matcher client", zap.Error(err))
 	}
 
-	FileStorageClient, err := s3.New(ctx, clients.FileStorageService_Addr)
+	FileStorageClient, err := s3.New(ctx, clients.FileStorageService_Addr, dialOptions(ctx, "file_storage", cfg.GRPC_Clients.FileStorage)...)
 	if err != nil {
 		log.Error("failed to connect storage client", zap.Error(err))
 	}
-	ChatClient, err := chat.New(ctx, clients.ChatService_Addr)
+	ChatClient, err := chat.New(ctx, clients.ChatService_Addr, dialOptions(ctx, "chat", cfg.GRPC_Clients.Chat)...)
 	if err != nil {
 		log.Error("failed to connect chat client", zap.Error(err))
 	}
 
-	NotificationClient, err := notification.New(ctx, clients.NotificationService_Addr)
+	NotificationClient, err := notification.New(ctx, clients.NotificationService_Addr, dialOptions(ctx, "notification", cfg.GRPC_Clients.Notification)...)
 	if err != nil {
 		log.Error("failed to connect notification client", zap.Error(err))
 	}
 
 	// handlers
 
-	AuthHandler := auth_handler.NewAuthHandler(AuthClient, cfg.Domain)
-	UserHandler := user_handler.NewUserHandler(UserClient, FileStorageClient)
-	MatcherHandler := matcher_handler.NewMatcherHandler(MatcherClient, FileStorageClient)
-	ChatHandler := chat_handler.NewChatHandler(ChatClient)
-	NotificationHandler := notification_handler.NewNotificationHandler(NotificationClient)
+	streamRegistry := streams.NewRegistry(cfg.HTTP_Server.Reconnect_Delay)
+
+	cookieIssuer := cookies.NewIssuer(cfg)
+
+	oauthProviders, err := auth_handler.NewProviders(cfg.Auth.OAuth.Providers, AuthClient)
+	if err != nil {
+		panic(err)
+	}
+	AuthHandler := auth_handler.NewAuthHandler(AuthClient, oauthProviders, cookieIssuer, cfg.Auth)
+	photoResolver := photos.NewResolver(FileStorageClient, cfg.Photos)
+
+	jobStore, err := jobs.NewStore(cfg.Jobs.Dir)
+	if err != nil {
+		panic(err)
+	}
+	accountDeletion := deletion.NewSaga(jobStore, UserClient, MatcherClient, ChatClient, AuthClient, streamRegistry, cfg.Jobs.StepTimeout)
+	go accountDeletion.Resume(ctx)
+
+	dataExport := export.NewExporter(jobStore, UserClient, MatcherClient, ChatClient, NotificationClient, FileStorageClient, cfg.Jobs)
+	go dataExport.Resume(ctx)
+	go dataExport.Watch(ctx, cfg.Jobs.CleanupInterval)
+
+	UserHandler := user_handler.NewUserHandler(UserClient, FileStorageClient, photoResolver, accountDeletion, dataExport, cookieIssuer, cfg.Users)
+	MatcherHandler := matcher_handler.NewMatcherHandler(MatcherClient, FileStorageClient, photoResolver)
+	ChatHandler := chat_handler.NewChatHandler(ChatClient, streamRegistry)
+	NotificationHandler := notification_handler.NewNotificationHandler(NotificationClient, streamRegistry)
+
+	backends := []health_handler.Backend{
+		{Name: "auth", Conn: AuthClient.Conn()},
+		{Name: "user", Conn: UserClient.Conn()},
+		{Name: "matcher", Conn: MatcherClient.Conn()},
+		{Name: "file_storage", Conn: FileStorageClient.Conn()},
+		{Name: "chat", Conn: ChatClient.Conn()},
+		{Name: "notification", Conn: NotificationClient.Conn()},
+	}
+	HealthHandler := health_handler.NewHealthHandler(backends, cfg.Health)
 
 	// middlewares
 
@@ -95,26 +151,52 @@ func New(ctx context.Context, cfg *config.Config, clients Clients) *App {
 		panic(err)
 	}
 
-	pubKey, err := decodeKeys.DecodePublicKey(cfg.PublicKey)
+	keySet, err := authn.NewKeySet(ctx, cfg.Keys, cfg.PublicKey)
 	if err != nil {
 		panic(err)
 	}
+	go keySet.Watch(ctx, cfg.Keys.RefreshInterval)
 
-	authMiddleware := authMid.NewAuthMiddleware("access_token", AuthClient, pubKey)
+	blockedUsers := middlewares.NewBlockedUsers(AuthClient, streamRegistry, cookieIssuer, cfg.Auth.BlockedCacheTTL)
+	go blockedUsers.Watch(ctx, cfg.Auth.BlockedRecheckInterval)
+
+	var refresher *authn.Refresher
+	if cfg.Auth.Refresh.Transparent {
+		refresher = authn.NewRefresher(AuthClient, cookieIssuer, cfg.Auth.Refresh.ResultTTL)
+	}
+
+	authenticator := authn.New(keySet, refresher)
+	authMiddleware := func(next http.Handler) http.Handler {
+		return authenticator.Middleware(blockedUsers.Middleware(next))
+	}
 
 	router.Use(middlewares.Cors(cfg))
 	router.Use(middleware.RequestID)
+	router.Use(requestinfo.Middleware)
+	router.Use(tracing.Middleware)
+	router.Use(metrics.Middleware)
 	router.Use(middleware.Recoverer)
 	router.Use(middleware.URLFormat)
 	router.Use(loggingMiddleware)
+	router.Use(middlewares.CSRF(cfg, cookieIssuer))
+
+	// health
+	router.Get("/healthz", HealthHandler.Liveness)
+	router.Get("/readyz", HealthHandler.Readiness)
 
 	// auth
-	router.Get("/api/v1/auth/google/login", AuthHandler.GoogleLoginURL)
-	router.Get("/api/v1/auth/google/callback", AuthHandler.GoogleAuthorize)
-	router.Get("/api/v1/auth/yandex/login", AuthHandler.YandexLoginURL)
-	router.Get("/api/v1/auth/yandex/callback", AuthHandler.YandexAuthorize)
+	router.Post("/api/v1/auth/phone/register", AuthHandler.PhoneRegister)
+	router.Post("/api/v1/auth/phone/login", AuthHandler.PhoneLogin)
+	router.Post("/api/v1/auth/phone/verify", AuthHandler.PhoneVerify)
+	router.Get("/api/v1/auth/providers", AuthHandler.ListProviders)
+	router.Get("/api/v1/auth/{provider}/login", AuthHandler.OAuthLoginURL)
+	router.Get("/api/v1/auth/{provider}/callback", AuthHandler.OAuthCallback)
 	router.Head("/api/v1/auth/refresh", AuthHandler.RefreshToken)
+	router.Post("/api/v1/auth/refresh", AuthHandler.RefreshToken)
 	router.With(authMiddleware).Delete("/api/v1/auth/logout", AuthHandler.Logout)
+	router.With(authMiddleware).Get("/api/v1/auth/sessions", AuthHandler.ListSessions)
+	router.With(authMiddleware).Delete("/api/v1/auth/sessions", AuthHandler.RevokeAllSessions)
+	router.With(authMiddleware).Delete("/api/v1/auth/sessions/{id}", AuthHandler.RevokeSession)
 
 	// user
 
@@ -123,7 +205,14 @@ func New(ctx context.Context, cfg *config.Config, clients Clients) *App {
 	router.Get("/api/v1/users", UserHandler.GetUsers)
 	router.With(authMiddleware).Get("/api/v1/user/session", UserHandler.GetSession)
 	router.With(authMiddleware).Put("/api/v1/user", UserHandler.UpdateUser)
+	router.With(authMiddleware).Patch("/api/v1/user", UserHandler.PatchUser)
+	router.With(authMiddleware).Put("/api/v1/user/avatar", UserHandler.PutAvatar)
+	router.With(authMiddleware).Delete("/api/v1/user/avatar", UserHandler.DeleteAvatar)
 	router.With(authMiddleware).Delete("/api/v1/user", UserHandler.DeleteUser)
+	router.With(authMiddleware).Get("/api/v1/user/deletion", UserHandler.GetDeletion)
+	router.With(authMiddleware).Post("/api/v1/user/export", UserHandler.StartExport)
+	router.With(authMiddleware).Get("/api/v1/user/export/{id}", UserHandler.GetExport)
+	router.With(authMiddleware).Get("/api/v1/user/export/{id}/download", UserHandler.DownloadExport)
 
 	// matcher
 
@@ -159,6 +248,9 @@ func New(ctx context.Context, cfg *config.Config, clients Clients) *App {
 	router.With(authMiddleware).Put("/api/v1/notifications/read", NotificationHandler.ReadNotification)
 	router.With(authMiddleware).Get("/api/v1/notifications/stream", NotificationHandler.GettingNotifications)
 
+	// metrics
+	router.Handle("/metrics", metrics.Handler())
+
 	// swagger
 	router.Get("/swagger/*", httpSwagger.Handler(
 		httpSwagger.URL("/swagger/doc.json"),
@@ -171,27 +263,189 @@ func New(ctx context.Context, cfg *config.Config, clients Clients) *App {
 		ReadTimeout:  cfg.HTTP_Server.Timeout,
 		WriteTimeout: 0,
 		IdleTimeout:  cfg.HTTP_Server.Idle_Timeout,
+		Protocols:    new(http.Protocols),
+	}
+	httpServer.Protocols.SetHTTP1(true)
+	httpServer.Protocols.SetUnencryptedHTTP2(cfg.HTTP_Server.H2C)
+
+	var redirectServer *http.Server
+	if tlsCfg := cfg.HTTP_Server.TLS; tlsCfg.Enabled {
+		reloader, err := tlsreload.New(tlsCfg.CertFile, tlsCfg.KeyFile, "")
+		if err != nil {
+			panic(err)
+		}
+		go reloader.Watch(ctx, tlsCfg.ReloadInterval)
+
+		httpServer.Protocols.SetHTTP2(true)
+		httpServer.TLSConfig = &tls.Config{
+			MinVersion:     tlsCfg.MinTLSVersion(),
+			GetCertificate: reloader.GetCertificate,
+		}
+
+		if tlsCfg.RedirectAddress != "" {
+			redirectServer = &http.Server{
+				Addr:              tlsCfg.RedirectAddress,
+				Handler:           httpsRedirect(cfg.HTTP_Server.Address),
+				ReadHeaderTimeout: cfg.HTTP_Server.Timeout,
+			}
+		}
+	}
+
+	conns := make([]*grpc.ClientConn, 0, len(backends))
+	for _, backend := range backends {
+		if backend.Conn != nil {
+			conns = append(conns, backend.Conn)
+		}
 	}
 
 	return &App{
-		router:     router,
-		httpServer: &httpServer,
+		router:          router,
+		httpServer:      &httpServer,
+		redirectServer:  redirectServer,
+		health:          HealthHandler,
+		streams:         streamRegistry,
+		conns:           conns,
+		shutdownTimeout: cfg.HTTP_Server.Shutdown_Timeout,
+	}
+}
+
+// dialOptions returns the gRPC dial options shared by all backend clients.
+// The breaker sees a call with all its retries as one outcome, retries
+// wrap the per-attempt deadline.
+func dialOptions(ctx context.Context, backend string, cfg config.Backend) []grpc.DialOption {
+	creds, err := transportCredentials(ctx, cfg.TLS)
+	if err != nil {
+		panic(fmt.Errorf("%s backend: %w", backend, err))
+	}
+
+	breaker := interceptors.NewBreaker(backend, cfg.Breaker, cfg.Bulkhead)
+
+	return []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(
+			metrics.UnaryClientInterceptor(backend),
+			breaker.UnaryInterceptor(),
+			interceptors.RetryUnaryInterceptor(cfg.Retry),
+			interceptors.DeadlineUnaryInterceptor(cfg),
+		),
+		grpc.WithChainStreamInterceptor(
+			metrics.StreamClientInterceptor(backend),
+			breaker.StreamInterceptor(),
+		),
 	}
 }
 
+// transportCredentials builds the credentials for the configured TLS mode.
+// Certificates are reloaded in the background when the files change.
+func transportCredentials(ctx context.Context, cfg config.BackendTLS) (credentials.TransportCredentials, error) {
+	if cfg.Mode == config.TLSModeInsecure {
+		return insecure.NewCredentials(), nil
+	}
+
+	certFile, keyFile := "", ""
+	if cfg.Mode == config.TLSModeMTLS {
+		certFile, keyFile = cfg.CertFile, cfg.KeyFile
+	}
+
+	reloader, err := tlsreload.New(certFile, keyFile, cfg.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	go reloader.Watch(ctx, cfg.ReloadInterval)
+
+	return credentials.NewTLS(reloader.ClientConfig(cfg.ServerName)), nil
+}
+
 func (a *App) MustStart(ctx context.Context) {
 	log, err := logger.LoggerFromCtx(ctx)
 	if err != nil {
 		panic(err)
 	}
 
-	if err := a.httpServer.ListenAndServe(); err != nil {
+	if a.redirectServer != nil {
+		go func() {
+			if err := a.redirectServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Error("failed to start redirect server", zap.Error(err))
+
+				panic(err)
+			}
+		}()
+	}
+
+	if a.httpServer.TLSConfig != nil {
+		err = a.httpServer.ListenAndServeTLS("", "")
+	} else {
+		err = a.httpServer.ListenAndServe()
+	}
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Error("failed to start http server", zap.Error(err))
 
 		panic(err)
 	}
 }
 
-func (a *App) MustStop() {
-	panic(a.httpServer.Shutdown(context.Background()))
+// httpsRedirect redirects plaintext requests to the HTTPS listener on addr.
+func httpsRedirect(addr string) http.Handler {
+	_, port, _ := net.SplitHostPort(addr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// Stop gracefully shuts the server down. Readiness starts failing, streaming
+// clients are asked to reconnect and in-flight requests are given the grace
+// period to finish. Whatever is left after that is closed forcibly.
+func (a *App) Stop(ctx context.Context) error {
+	log, err := logger.LoggerFromCtx(ctx)
+	if err != nil {
+		return err
+	}
+
+	a.health.SetDraining()
+	a.streams.Drain()
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, a.shutdownTimeout)
+	defer cancel()
+
+	// Shutdown does not track hijacked WebSocket connections, so they are
+	// waited for separately.
+	err = a.httpServer.Shutdown(shutdownCtx)
+	if err == nil {
+		err = a.streams.Wait(shutdownCtx)
+	}
+
+	var errs []error
+	if a.redirectServer != nil {
+		if err := a.redirectServer.Shutdown(shutdownCtx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err != nil {
+		log.Warn("grace period expired, closing remaining connections", zap.Error(err))
+
+		a.streams.CloseAll()
+		if err := a.httpServer.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, conn := range a.conns {
+		if err := conn.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
 }
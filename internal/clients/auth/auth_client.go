@@ -3,32 +3,44 @@ package auth
 import (
 	"context"
 
+	"api-gateway/internal/clients/interceptors"
 	handler "api-gateway/internal/ports/handlers/auth_handler"
 
-	authInt "github.com/hesoyamTM/nbf-auth/pkg/auth"
 	authv1 "github.com/hesoyamTM/nbf-protos/gen/go/auth"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 type Client struct {
 	api authv1.AuthClient
+	cc  *grpc.ClientConn
 }
 
-func New(ctx context.Context, address string) (*Client, error) {
-	cc, err := grpc.NewClient(address,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithUnaryInterceptor(authInt.SettingMetadataInterceptor()),
-	)
+func New(ctx context.Context, address string, opts ...grpc.DialOption) (*Client, error) {
+	opts = append([]grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(interceptors.MetadataUnaryInterceptor()),
+		grpc.WithChainStreamInterceptor(interceptors.MetadataStreamInterceptor()),
+	}, opts...)
+
+	cc, err := grpc.NewClient(address, opts...)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Client{
 		api: authv1.NewAuthClient(cc),
+		cc:  cc,
 	}, nil
 }
 
+// Conn returns the underlying gRPC connection.
+func (c *Client) Conn() *grpc.ClientConn {
+	if c == nil {
+		return nil
+	}
+
+	return c.cc
+}
+
 func (c *Client) Register(ctx context.Context, phone_number, name, surname string) (string, error) {
 	resp, err := c.api.Register(ctx, &authv1.RegisterRequest{
 		PhoneNumber: phone_number,
@@ -64,11 +76,23 @@ func (c *Client) Logout(ctx context.Context, refresh_token string) error {
 	return nil
 }
 
-/*
-func (c *Client) VerifyPhoneNumber(ctx context.Context, token, code string) (handler.Tokens, error) {
+func (c *Client) VerifyPhoneNumber(ctx context.Context, token, code string) (*handler.Tokens, error) {
+	resp, err := c.api.VerifyPhoneNumber(ctx, &authv1.VerifyPhoneNumberRequest{
+		Token: token,
+		Code:  code,
+	})
+	if err != nil {
+		return nil, err
+	}
 
+	return &handler.Tokens{
+		AccessToken:       resp.GetAccessToken(),
+		RefreshToken:      resp.GetRefreshToken(),
+		Access_expire_at:  resp.GetAccessExpireAt().AsTime(),
+		Refresh_expire_at: resp.GetRefreshExpireAt().AsTime(),
+	}, nil
 }
-*/
+
 func (c *Client) RefreshToken(ctx context.Context, token string) (*handler.Tokens, error) {
 	resp, err := c.api.RefreshToken(ctx, &authv1.RefreshTokenRequest{
 		RefreshToken: token,
@@ -85,6 +109,24 @@ func (c *Client) RefreshToken(ctx context.Context, token string) (*handler.Token
 	}, nil
 }
 
+// OAuthProvider returns the OAuth provider of the auth service by name.
+func (c *Client) OAuthProvider(name string) (handler.OAuthProvider, bool) {
+	switch name {
+	case "google":
+		return handler.OAuthProviderFuncs{
+			LoginURLFunc:  c.GoogleLoginURL,
+			AuthorizeFunc: c.GoogleAuthorize,
+		}, true
+	case "yandex":
+		return handler.OAuthProviderFuncs{
+			LoginURLFunc:  c.YandexLoginURL,
+			AuthorizeFunc: c.YandexAuthorize,
+		}, true
+	default:
+		return nil, false
+	}
+}
+
 func (c *Client) YandexLoginURL(ctx context.Context) (string, error) {
 	resp, err := c.api.YandexLoginURL(ctx, &authv1.YandexLoginURLRequest{})
 	if err != nil {
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 
+	"api-gateway/internal/clients/interceptors"
 	"api-gateway/internal/models"
 
 	"github.com/hesoyamTM/nbf-auth/pkg/auth"
@@ -12,28 +13,40 @@ import (
 	chatv1 "github.com/hesoyamTM/nbf-protos/gen/go/chat"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
 )
 
 type Client struct {
 	api chatv1.ChatServiceClient
+	cc  *grpc.ClientConn
 }
 
-func New(ctx context.Context, address string) (*Client, error) {
-	cc, err := grpc.NewClient(
-		address,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
+func New(ctx context.Context, address string, opts ...grpc.DialOption) (*Client, error) {
+	opts = append([]grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(interceptors.MetadataUnaryInterceptor()),
+		grpc.WithChainStreamInterceptor(interceptors.MetadataStreamInterceptor()),
+	}, opts...)
+
+	cc, err := grpc.NewClient(address, opts...)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Client{
 		api: chatv1.NewChatServiceClient(cc),
+		cc:  cc,
 	}, nil
 }
 
+// Conn returns the underlying gRPC connection.
+func (c *Client) Conn() *grpc.ClientConn {
+	if c == nil {
+		return nil
+	}
+
+	return c.cc
+}
+
 func (c *Client) SendMessage(
 	ctx context.Context,
 	chatID string,
@@ -55,6 +68,7 @@ func (c *Client) SendMessage(
 		return nil, fmt.Errorf("%s: user id not found in context", op)
 	}
 
+	// uid and request metadata are attached by interceptors.MetadataStreamInterceptor
 	md := make(map[string]string)
 	if chatID != "" {
 		md["chat_id"] = chatID
@@ -65,7 +79,6 @@ func (c *Client) SendMessage(
 	if groupID != "" {
 		md["group_id"] = groupID
 	}
-	md["uid"] = uid
 
 	ctx = metadata.NewOutgoingContext(ctx, metadata.New(md))
 
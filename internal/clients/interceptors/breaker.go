@@ -0,0 +1,206 @@
+package interceptors
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"api-gateway/internal/config"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// bulkheadRetryAfter is suggested to clients when a backend has no free slots.
+const bulkheadRetryAfter = time.Second
+
+// UnavailableError is returned without calling the backend when its circuit
+// breaker is open or its concurrency limit is reached.
+type UnavailableError struct {
+	Backend string
+	Reason  string
+	After   time.Duration
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("%s is unavailable: %s", e.Backend, e.Reason)
+}
+
+// GRPCStatus makes the error look like any other Unavailable backend error.
+func (e *UnavailableError) GRPCStatus() *status.Status {
+	return status.New(codes.Unavailable, e.Error())
+}
+
+// RetryAfter is the time after which the backend may be called again.
+func (e *UnavailableError) RetryAfter() time.Duration {
+	return e.After
+}
+
+type breakerState int
+
+const (
+	stateClosed breakerState = iota
+	stateOpen
+	stateHalfOpen
+)
+
+// Breaker is a consecutive-failures circuit breaker with a concurrency
+// limiter (bulkhead) in front of a single backend.
+type Breaker struct {
+	backend string
+	cfg     config.Breaker
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	probes   int
+
+	slots chan struct{}
+}
+
+func NewBreaker(backend string, cfg config.Breaker, bulkhead config.Bulkhead) *Breaker {
+	var slots chan struct{}
+	if limit := bulkhead.Limit(); limit > 0 {
+		slots = make(chan struct{}, limit)
+	}
+
+	return &Breaker{
+		backend: backend,
+		cfg:     cfg,
+		slots:   slots,
+	}
+}
+
+// UnaryInterceptor fails fast while the breaker is open and limits the
+// number of concurrent calls.
+func (b *Breaker) UnaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if err := b.allow(); err != nil {
+			return err
+		}
+
+		release, err := b.acquire()
+		if err != nil {
+			b.abort()
+			return err
+		}
+		defer release()
+
+		err = invoker(ctx, method, req, reply, cc, opts...)
+		b.record(err)
+
+		return err
+	}
+}
+
+// StreamInterceptor guards stream establishment. Streams are long-lived,
+// so they do not take bulkhead slots.
+func (b *Breaker) StreamInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if err := b.allow(); err != nil {
+			return nil, err
+		}
+
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		b.record(err)
+
+		return stream, err
+	}
+}
+
+func (b *Breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case stateOpen:
+		if wait := b.cfg.OpenTimeout - time.Since(b.openedAt); wait > 0 {
+			return &UnavailableError{
+				Backend: b.backend,
+				Reason:  "circuit breaker is open",
+				After:   wait,
+			}
+		}
+		b.state = stateHalfOpen
+		b.probes = 0
+		fallthrough
+	case stateHalfOpen:
+		if b.probes >= b.cfg.HalfOpenRequests {
+			return &UnavailableError{
+				Backend: b.backend,
+				Reason:  "circuit breaker is half-open",
+				After:   b.cfg.OpenTimeout,
+			}
+		}
+		b.probes++
+	}
+
+	return nil
+}
+
+func (b *Breaker) record(err error) {
+	if status.Code(err) == codes.Canceled {
+		// the caller went away, this says nothing about the backend
+		b.abort()
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !isFailure(err) {
+		// any answer from the backend, even an error about the request,
+		// means it is alive again
+		b.state = stateClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == stateHalfOpen || b.failures >= b.cfg.FailureThreshold {
+		b.state = stateOpen
+		b.openedAt = time.Now()
+		b.failures = 0
+	}
+}
+
+// abort gives back a half-open probe that was not used.
+func (b *Breaker) abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == stateHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+func (b *Breaker) acquire() (func(), error) {
+	if b.slots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case b.slots <- struct{}{}:
+		return func() { <-b.slots }, nil
+	default:
+		return nil, &UnavailableError{
+			Backend: b.backend,
+			Reason:  "too many concurrent requests",
+			After:   bulkheadRetryAfter,
+		}
+	}
+}
+
+// isFailure reports whether the error says something about the health of
+// the backend rather than about the request.
+func isFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Internal, codes.Unknown:
+		return true
+	default:
+		return false
+	}
+}
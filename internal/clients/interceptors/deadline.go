@@ -0,0 +1,39 @@
+package interceptors
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"api-gateway/internal/config"
+
+	"google.golang.org/grpc"
+)
+
+// DeadlineUnaryInterceptor bounds every unary call with the timeout configured
+// for its method or the backend default. An earlier deadline of the caller
+// is kept.
+func DeadlineUnaryInterceptor(cfg config.Backend) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		timeout := cfg.Timeout
+		if methodTimeout, ok := cfg.MethodTimeouts[methodName(method)]; ok {
+			timeout = methodTimeout
+		}
+
+		if timeout > 0 {
+			if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > timeout {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+		}
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// methodName returns the short method name of a full gRPC method,
+// "/user.User/GetUser" becomes "GetUser".
+func methodName(fullMethod string) string {
+	return fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+}
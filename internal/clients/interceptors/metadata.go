@@ -0,0 +1,76 @@
+// Package interceptors contains gRPC client interceptors shared by all
+// backend clients.
+package interceptors
+
+import (
+	"context"
+	"encoding/base64"
+
+	"api-gateway/internal/requestinfo"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/hesoyamTM/nbf-auth/pkg/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	RequestIDKey      = "x-request-id"
+	ClientIPKey       = "x-forwarded-for"
+	UserAgentKey      = "x-user-agent"
+	IdempotencyKeyKey = "idempotency-key"
+)
+
+// MetadataUnaryInterceptor forwards the request ID, the authenticated user
+// and the client IP, user-agent and idempotency key to the backend.
+func MetadataUnaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(withMetadata(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// MetadataStreamInterceptor is the streaming counterpart of MetadataUnaryInterceptor.
+func MetadataStreamInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(withMetadata(ctx), desc, cc, method, opts...)
+	}
+}
+
+// withMetadata adds the gateway metadata on top of whatever the client
+// has already put into the outgoing context.
+func withMetadata(ctx context.Context) context.Context {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+
+	uid, _ := ctx.Value(auth.UID).(string)
+	name, _ := ctx.Value(auth.NAME).(string)
+	surname, _ := ctx.Value(auth.SURNAME).(string)
+
+	// the same encoding as auth.SettingMetadataInterceptor, backends decode
+	// it with auth.TakingMetadataInterceptor
+	md.Set(auth.UidInterceptor, uid)
+	md.Set(auth.NameInterceptor, base64.URLEncoding.EncodeToString([]byte(name)))
+	md.Set(auth.SurnameInterceptor, base64.URLEncoding.EncodeToString([]byte(surname)))
+
+	if reqID := middleware.GetReqID(ctx); reqID != "" {
+		md.Set(RequestIDKey, reqID)
+	}
+
+	if info, ok := requestinfo.From(ctx); ok {
+		if info.ClientIP != "" {
+			md.Set(ClientIPKey, info.ClientIP)
+		}
+		if info.UserAgent != "" {
+			md.Set(UserAgentKey, info.UserAgent)
+		}
+		if info.IdempotencyKey != "" {
+			md.Set(IdempotencyKeyKey, info.IdempotencyKey)
+		}
+	}
+
+	return metadata.NewOutgoingContext(ctx, md)
+}
@@ -0,0 +1,134 @@
+package interceptors
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/requestinfo"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// retryBudgetCap is the maximum number of retries that can be saved up.
+const retryBudgetCap = 10
+
+// idempotentMethods are reads that are always safe to retry.
+var idempotentMethods = map[string]bool{
+	"GetUser":       true,
+	"GetGroup":      true,
+	"GetFormByUser": true,
+	"FindGroups":    true,
+	"GetPhotoURL":   true,
+}
+
+// RetryUnaryInterceptor retries idempotent reads with exponential backoff.
+// Writes are retried only when the request carries an idempotency key.
+// Deadlines must be applied per attempt by an interceptor further down
+// the chain.
+func RetryUnaryInterceptor(cfg config.Retry) grpc.UnaryClientInterceptor {
+	methods := make(map[string]bool, len(idempotentMethods)+len(cfg.Methods))
+	for method := range idempotentMethods {
+		methods[method] = true
+	}
+	for _, method := range cfg.Methods {
+		methods[method] = true
+	}
+
+	budget := newRetryBudget(cfg.Budget())
+
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		budget.deposit()
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if err == nil || (!methods[methodName(method)] && !hasIdempotencyKey(ctx)) {
+			return err
+		}
+
+		backoff := cfg.InitialBackoff
+		for attempt := 1; attempt < cfg.MaxAttempts && retryable(ctx, err); attempt++ {
+			if !budget.withdraw() {
+				break
+			}
+
+			// full jitter
+			timer := time.NewTimer(time.Duration(rand.Int64N(int64(backoff) + 1)))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+
+			err = invoker(ctx, method, req, reply, cc, opts...)
+			if err == nil {
+				return nil
+			}
+
+			backoff = min(backoff*2, cfg.MaxBackoff)
+		}
+
+		return err
+	}
+}
+
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Aborted, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
+
+func hasIdempotencyKey(ctx context.Context) bool {
+	info, ok := requestinfo.From(ctx)
+
+	return ok && info.IdempotencyKey != ""
+}
+
+// retryBudget is a token bucket: every call deposits ratio tokens, every
+// retry withdraws a whole token. A zero ratio never allows a retry.
+type retryBudget struct {
+	mu     sync.Mutex
+	tokens float64
+	ratio  float64
+}
+
+func newRetryBudget(ratio float64) *retryBudget {
+	if ratio <= 0 {
+		return &retryBudget{}
+	}
+
+	return &retryBudget{
+		tokens: retryBudgetCap,
+		ratio:  ratio,
+	}
+}
+
+func (b *retryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(b.tokens+b.ratio, retryBudgetCap)
+}
+
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+
+	return true
+}
@@ -3,12 +3,11 @@ package matcher
 import (
 	"context"
 
+	"api-gateway/internal/clients/interceptors"
 	dto "api-gateway/internal/ports/handlers/matcher_handler"
 
-	authInt "github.com/hesoyamTM/nbf-auth/pkg/auth"
 	matcherv1 "github.com/hesoyamTM/nbf-protos/gen/go/matcher"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 type Client struct {
@@ -16,13 +15,17 @@ type Client struct {
 	GroupQueryApi        matcherv1.GroupQueryServiceClient
 	FindGroupsServiceApi matcherv1.FindGroupServiceClient
 	GroupServiceApi      matcherv1.GroupServiceClient
+
+	cc *grpc.ClientConn
 }
 
-func New(ctx context.Context, address string) (*Client, error) {
-	cc, err := grpc.NewClient(address,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithUnaryInterceptor(authInt.SettingMetadataInterceptor()),
-	)
+func New(ctx context.Context, address string, opts ...grpc.DialOption) (*Client, error) {
+	opts = append([]grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(interceptors.MetadataUnaryInterceptor()),
+		grpc.WithChainStreamInterceptor(interceptors.MetadataStreamInterceptor()),
+	}, opts...)
+
+	cc, err := grpc.NewClient(address, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -32,9 +35,19 @@ func New(ctx context.Context, address string) (*Client, error) {
 		GroupQueryApi:        matcherv1.NewGroupQueryServiceClient(cc),
 		FindGroupsServiceApi: matcherv1.NewFindGroupServiceClient(cc),
 		GroupServiceApi:      matcherv1.NewGroupServiceClient(cc),
+		cc:                   cc,
 	}, nil
 }
 
+// Conn returns the underlying gRPC connection.
+func (c *Client) Conn() *grpc.ClientConn {
+	if c == nil {
+		return nil
+	}
+
+	return c.cc
+}
+
 ////////////////////////////////////
 
 func (c *Client) CreateForm(ctx context.Context, uid string, protoParams *matcherv1.Parameters) error {
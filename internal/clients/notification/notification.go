@@ -4,33 +4,46 @@ import (
 	"context"
 	"fmt"
 
+	"api-gateway/internal/clients/interceptors"
 	"api-gateway/internal/models"
 
 	"github.com/hesoyamTM/nbf-auth/pkg/logger"
 	notificationv1 "github.com/hesoyamTM/nbf-protos/gen/go/notification"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 type Client struct {
 	api notificationv1.NotificationServiceClient
+	cc  *grpc.ClientConn
 }
 
-func New(ctx context.Context, address string) (*Client, error) {
-	cc, err := grpc.NewClient(
-		address,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
+func New(ctx context.Context, address string, opts ...grpc.DialOption) (*Client, error) {
+	opts = append([]grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(interceptors.MetadataUnaryInterceptor()),
+		grpc.WithChainStreamInterceptor(interceptors.MetadataStreamInterceptor()),
+	}, opts...)
+
+	cc, err := grpc.NewClient(address, opts...)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Client{
 		api: notificationv1.NewNotificationServiceClient(cc),
+		cc:  cc,
 	}, nil
 }
 
+// Conn returns the underlying gRPC connection.
+func (c *Client) Conn() *grpc.ClientConn {
+	if c == nil {
+		return nil
+	}
+
+	return c.cc
+}
+
 func (c *Client) GetNotificationList(
 	ctx context.Context,
 	userID string,
@@ -1,35 +1,47 @@
 package s3
 
 import (
+	"api-gateway/internal/clients/interceptors"
 	models "api-gateway/internal/ports/handlers/user_handler"
 	"context"
 	"io"
 
 	s3v1 "github.com/acyushka/nbf-file-storage-service/pkg/pb/gen"
 
-	authInt "github.com/hesoyamTM/nbf-auth/pkg/auth"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 type FileStorageClient struct {
 	api s3v1.FileStorageServiceClient
+	cc  *grpc.ClientConn
 }
 
-func New(ctx context.Context, address string) (*FileStorageClient, error) {
-	cc, err := grpc.NewClient(address,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithUnaryInterceptor(authInt.SettingMetadataInterceptor()),
-	)
+func New(ctx context.Context, address string, opts ...grpc.DialOption) (*FileStorageClient, error) {
+	opts = append([]grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(interceptors.MetadataUnaryInterceptor()),
+		grpc.WithChainStreamInterceptor(interceptors.MetadataStreamInterceptor()),
+	}, opts...)
+
+	cc, err := grpc.NewClient(address, opts...)
 	if err != nil {
 		return nil, err
 	}
 
 	return &FileStorageClient{
 		api: s3v1.NewFileStorageServiceClient(cc),
+		cc:  cc,
 	}, nil
 }
 
+// Conn returns the underlying gRPC connection.
+func (c *FileStorageClient) Conn() *grpc.ClientConn {
+	if c == nil {
+		return nil
+	}
+
+	return c.cc
+}
+
 func (c *FileStorageClient) UploadAvatar(ctx context.Context, userID string, file *models.FilePhoto) (string, error) {
 	fileData, err := io.ReadAll(file.Data)
 	if err != nil {
@@ -1,13 +1,12 @@
 package user
 
 import (
+	"api-gateway/internal/clients/interceptors"
 	models "api-gateway/internal/ports/handlers/user_handler"
 	"context"
 
-	authInt "github.com/hesoyamTM/nbf-auth/pkg/auth"
 	userv1 "github.com/hesoyamTM/nbf-protos/gen/go/user"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 type UserInfo struct {
@@ -21,22 +20,35 @@ type UserInfo struct {
 
 type Client struct {
 	api userv1.UserClient
+	cc  *grpc.ClientConn
 }
 
-func New(ctx context.Context, address string) (*Client, error) {
-	cc, err := grpc.NewClient(address,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithUnaryInterceptor(authInt.SettingMetadataInterceptor()),
-	)
+func New(ctx context.Context, address string, opts ...grpc.DialOption) (*Client, error) {
+	opts = append([]grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(interceptors.MetadataUnaryInterceptor()),
+		grpc.WithChainStreamInterceptor(interceptors.MetadataStreamInterceptor()),
+	}, opts...)
+
+	cc, err := grpc.NewClient(address, opts...)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Client{
 		api: userv1.NewUserClient(cc),
+		cc:  cc,
 	}, nil
 }
 
+// Conn returns the underlying gRPC connection.
+func (c *Client) Conn() *grpc.ClientConn {
+	if c == nil {
+		return nil
+	}
+
+	return c.cc
+}
+
 func (c *Client) CreateUser(ctx context.Context, user *models.User) error {
 	_, err := c.api.CreateUser(ctx, &userv1.CreateUserRequest{
 		User: &userv1.UserInfo{
@@ -108,6 +120,40 @@ func (c *Client) UpdateUser(ctx context.Context, user *models.User) error {
 	return err
 }
 
+// UpdateUserFields writes the masked fields of the user and returns the
+// updated user. The user service replaces the whole profile and has no
+// field mask, so the mask is applied to the current profile here. Until
+// the proto gains a field mask this is a read-modify-write and the last
+// write wins: a field changed by another update between the read and the
+// write is overwritten with the value read.
+func (c *Client) UpdateUserFields(ctx context.Context, update *models.UserUpdate) (*models.User, error) {
+	user, err := c.GetUser(ctx, update.User.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, field := range update.Mask {
+		switch field {
+		case models.FieldName:
+			user.Name = update.User.Name
+		case models.FieldSurname:
+			user.Surname = update.User.Surname
+		case models.FieldContacts:
+			user.Contacts = update.User.Contacts
+		case models.FieldAvatar:
+			user.Avatar = update.User.Avatar
+		case models.FieldDescription:
+			user.Description = update.User.Description
+		}
+	}
+
+	if err := c.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
 func (c *Client) DeleteUser(ctx context.Context, uid string) error {
 	_, err := c.api.DeleteUser(ctx, &userv1.DeleteUserRequest{
 		Id: uid,
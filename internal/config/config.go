@@ -1,14 +1,39 @@
 package config
 
-import "time"
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	EnvDev  = "dev"
+	EnvProd = "prod"
+)
+
+const (
+	TLSModeInsecure = "insecure"
+	TLSModeTLS      = "tls"
+	TLSModeMTLS     = "mtls"
+)
 
 type Config struct {
 	Env          string      `yaml:"env" env-default:"dev"`
 	Domain       string      `yaml:"domain"`
-	PublicKey    string      `env:"PUBLIC_KEY" env-required:"true"`
+	PublicKey    string      `env:"PUBLIC_KEY"`
+	Keys         Keys        `yaml:"keys"`
 	GRPC_Clients GrpcClients `yaml:"grpc_clients"`
 	HTTP_Server  HttpServer  `yaml:"http_server"`
 	CORS         CORS        `yaml:"cors"`
+	Health       Health      `yaml:"health"`
+	Tracing      Tracing     `yaml:"tracing"`
+	Auth         Auth        `yaml:"auth"`
+	Cookies      Cookies     `yaml:"cookies"`
+	CSRF         CSRF        `yaml:"csrf"`
+	Photos       Photos      `yaml:"photos"`
+	Users        Users       `yaml:"users"`
+	Jobs         Jobs        `yaml:"jobs"`
 }
 
 type GrpcClients struct {
@@ -18,12 +43,113 @@ type GrpcClients struct {
 	FileStorageService  string `yaml:"file_storage_service_address"`
 	ChatService         string `yaml:"chat_service_address"`
 	NotificationService string `yaml:"notification_service_address"`
+
+	Auth         Backend `yaml:"auth"`
+	User         Backend `yaml:"user"`
+	Matcher      Backend `yaml:"matcher"`
+	FileStorage  Backend `yaml:"file_storage"`
+	Chat         Backend `yaml:"chat"`
+	Notification Backend `yaml:"notification"`
+
+	// AllowInsecure permits plaintext connections to backends in prod.
+	AllowInsecure bool `yaml:"allow_insecure" env:"GRPC_ALLOW_INSECURE"`
+}
+
+// Backend holds call options of a single backend service.
+type Backend struct {
+	Timeout        time.Duration            `yaml:"timeout" env-default:"5s"`
+	MethodTimeouts map[string]time.Duration `yaml:"method_timeouts"`
+	Retry          Retry                    `yaml:"retry"`
+	Breaker        Breaker                  `yaml:"breaker"`
+	Bulkhead       Bulkhead                 `yaml:"bulkhead"`
+	TLS            BackendTLS               `yaml:"tls"`
+}
+
+// BackendTLS configures transport security of a backend connection.
+// CAFile is optional for tls and mtls, the system roots are used without it.
+// The files are checked for changes every ReloadInterval.
+type BackendTLS struct {
+	Mode           string        `yaml:"mode" env-default:"insecure"`
+	CAFile         string        `yaml:"ca_file"`
+	CertFile       string        `yaml:"cert_file"`
+	KeyFile        string        `yaml:"key_file"`
+	ServerName     string        `yaml:"server_name"`
+	ReloadInterval time.Duration `yaml:"reload_interval" env-default:"1m"`
+}
+
+// Retry configures retries of idempotent calls. BudgetRatio limits retries
+// to a share of the regular traffic, so that retries cannot multiply the
+// load on a struggling backend. It defaults to 0.1, 0 disables retries.
+type Retry struct {
+	MaxAttempts    int           `yaml:"max_attempts" env-default:"3"`
+	InitialBackoff time.Duration `yaml:"initial_backoff" env-default:"100ms"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" env-default:"1s"`
+	BudgetRatio    *float64      `yaml:"budget_ratio"`
+	Methods        []string      `yaml:"methods"`
+}
+
+// Budget returns BudgetRatio or its default.
+func (r Retry) Budget() float64 {
+	if r.BudgetRatio != nil {
+		return *r.BudgetRatio
+	}
+
+	return 0.1
+}
+
+// Breaker opens after FailureThreshold consecutive failures and rejects
+// calls for OpenTimeout, then lets HalfOpenRequests probes through.
+type Breaker struct {
+	FailureThreshold int           `yaml:"failure_threshold" env-default:"5"`
+	OpenTimeout      time.Duration `yaml:"open_timeout" env-default:"10s"`
+	HalfOpenRequests int           `yaml:"half_open_requests" env-default:"1"`
+}
+
+// Bulkhead limits concurrent unary calls to a backend. MaxConcurrent
+// defaults to 100, 0 means no limit.
+type Bulkhead struct {
+	MaxConcurrent *int `yaml:"max_concurrent"`
+}
+
+// Limit returns MaxConcurrent or its default.
+func (b Bulkhead) Limit() int {
+	if b.MaxConcurrent != nil {
+		return *b.MaxConcurrent
+	}
+
+	return 100
 }
 
 type HttpServer struct {
-	Address      string        `yaml:"address"`
-	Timeout      time.Duration `yaml:"timeout"`
-	Idle_Timeout time.Duration `yaml:"idle_timeout"`
+	Address          string        `yaml:"address"`
+	Timeout          time.Duration `yaml:"timeout"`
+	Idle_Timeout     time.Duration `yaml:"idle_timeout"`
+	Shutdown_Timeout time.Duration `yaml:"shutdown_timeout" env-default:"15s"`
+	Reconnect_Delay  time.Duration `yaml:"reconnect_delay" env-default:"3s"`
+	TLS              ServerTLS     `yaml:"tls"`
+	// H2C enables HTTP/2 without TLS (prior knowledge), for local development.
+	H2C bool `yaml:"h2c"`
+}
+
+// ServerTLS enables HTTPS on HttpServer.Address. RedirectAddress, when set,
+// is a plaintext listener that redirects everything to HTTPS.
+type ServerTLS struct {
+	Enabled         bool          `yaml:"enabled"`
+	CertFile        string        `yaml:"cert_file"`
+	KeyFile         string        `yaml:"key_file"`
+	MinVersion      string        `yaml:"min_version" env-default:"1.2"`
+	ReloadInterval  time.Duration `yaml:"reload_interval" env-default:"1m"`
+	RedirectAddress string        `yaml:"redirect_address"`
+}
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// MinTLSVersion returns MinVersion as a crypto/tls constant.
+func (t ServerTLS) MinTLSVersion() uint16 {
+	return tlsVersions[t.MinVersion]
 }
 
 type CORS struct {
@@ -32,3 +158,269 @@ type CORS struct {
 	AllowedHeaders   []string `yaml:"allowed_headers"`
 	AllowCredentials bool     `yaml:"allow_credentials"`
 }
+
+// Users limits the batch user lookup.
+type Users struct {
+	MaxBatchSize int `yaml:"max_batch_size" env-default:"100"`
+}
+
+// Jobs configures background jobs such as account deletion and data
+// export. Their progress and exported archives are kept in Dir, which must
+// survive restarts. Exports are removed ExportTTL after they are finished.
+// Photos are exported with a deadline of PhotoTimeout each rather than
+// StepTimeout for all of them.
+type Jobs struct {
+	Dir             string        `yaml:"dir" env:"JOBS_DIR" env-default:"./data/jobs"`
+	StepTimeout     time.Duration `yaml:"step_timeout" env-default:"30s"`
+	PhotoTimeout    time.Duration `yaml:"photo_timeout" env-default:"30s"`
+	ExportTTL       time.Duration `yaml:"export_ttl" env-default:"24h"`
+	MaxPhotoSize    int64         `yaml:"max_photo_size" env-default:"20971520"`
+	CleanupInterval time.Duration `yaml:"cleanup_interval" env-default:"1h"`
+}
+
+// Photos configures the resolution of photo IDs to presigned URLs. URLs are
+// cached until ExpiryMargin before the presigned expiry, or for CacheTTL if
+// the URL does not carry one. A lookup shared by concurrent requests runs
+// for at most Timeout, regardless of the request that started it.
+type Photos struct {
+	MaxConcurrent int           `yaml:"max_concurrent" env-default:"8"`
+	Timeout       time.Duration `yaml:"timeout" env-default:"5s"`
+	CacheTTL      time.Duration `yaml:"cache_ttl" env-default:"5m"`
+	ExpiryMargin  time.Duration `yaml:"expiry_margin" env-default:"1m"`
+	CacheSize     int           `yaml:"cache_size" env-default:"10000"`
+}
+
+type Health struct {
+	CriticalBackends []string      `yaml:"critical_backends"`
+	CheckProtocol    bool          `yaml:"check_protocol"`
+	Timeout          time.Duration `yaml:"timeout" env-default:"2s"`
+}
+
+// Auth configures authentication of HTTP requests. Block status of users
+// is cached for BlockedCacheTTL, users with open streams are re-checked
+// every BlockedRecheckInterval.
+type Auth struct {
+	BlockedCacheTTL        time.Duration `yaml:"blocked_cache_ttl" env-default:"30s"`
+	BlockedRecheckInterval time.Duration `yaml:"blocked_recheck_interval" env-default:"1m"`
+	Phone                  PhoneAuth     `yaml:"phone"`
+	OAuth                  OAuth         `yaml:"oauth"`
+	Refresh                Refresh       `yaml:"refresh"`
+}
+
+// Keys are the public keys that verify access tokens, selected by the kid
+// header of the token. They are merged from the PUBLIC_KEY env (kid ""),
+// PublicKeys, the *.pem files of Dir (kid is the file name without the
+// extension) and the JWKS at JWKSURL. Dir and JWKSURL are re-read every
+// RefreshInterval, so a new key can be published before tokens are signed
+// with it and the old one removed after they expire.
+type Keys struct {
+	PublicKeys      []PublicKey   `yaml:"public_keys"`
+	Dir             string        `yaml:"dir" env:"PUBLIC_KEYS_DIR"`
+	JWKSURL         string        `yaml:"jwks_url" env:"JWKS_URL"`
+	RefreshInterval time.Duration `yaml:"refresh_interval" env-default:"5m"`
+}
+
+type PublicKey struct {
+	KID string `yaml:"kid"`
+	PEM string `yaml:"pem"`
+}
+
+// Refresh enables the transparent refresh of expired access tokens sent in
+// cookies. ResultTTL is how long a refresh result is reused for requests
+// that still carry the old refresh token.
+type Refresh struct {
+	Transparent bool          `yaml:"transparent" env:"AUTH_TRANSPARENT_REFRESH" env-default:"false"`
+	ResultTTL   time.Duration `yaml:"result_ttl" env-default:"10s"`
+}
+
+// OAuth configures where the browser goes after an OAuth callback.
+// return_to must point to one of AllowedOrigins (or be a path on the origin
+// of DefaultReturnURL). Failures are redirected to ErrorURL with a reason.
+type OAuth struct {
+	DefaultReturnURL string        `yaml:"default_return_url"`
+	ErrorURL         string        `yaml:"error_url"`
+	AllowedOrigins   []string      `yaml:"allowed_origins"`
+	StateTTL         time.Duration `yaml:"state_ttl" env-default:"10m"`
+	// Providers enabled for login, google and yandex if empty.
+	Providers []OAuthProvider `yaml:"providers"`
+}
+
+// OAuthProvider is served at /auth/{name}/login and /auth/{name}/callback.
+// Title is the button label for the frontend.
+type OAuthProvider struct {
+	Name  string `yaml:"name"`
+	Title string `yaml:"title"`
+}
+
+// PhoneAuth limits the phone number sign-in. A code may be requested once
+// per ResendInterval and checked MaxAttempts times during CodeTTL.
+type PhoneAuth struct {
+	ResendInterval time.Duration `yaml:"resend_interval" env-default:"1m"`
+	MaxAttempts    int           `yaml:"max_attempts" env-default:"5"`
+	CodeTTL        time.Duration `yaml:"code_ttl" env-default:"10m"`
+}
+
+// Cookies is the policy of every cookie set by the gateway. Secure and
+// SameSite default to the environment: secure lax cookies in prod, lax
+// cookies without Secure in dev. Domain defaults to Config.Domain.
+// SameSite strict drops the OAuth state cookie on the provider redirect.
+type Cookies struct {
+	Domain   string `yaml:"domain"`
+	Path     string `yaml:"path" env-default:"/"`
+	Secure   *bool  `yaml:"secure"`
+	SameSite string `yaml:"same_site"`
+}
+
+const (
+	SameSiteLax    = "lax"
+	SameSiteStrict = "strict"
+	SameSiteNone   = "none"
+)
+
+// CSRF protects cookie-authenticated unsafe requests. A request passes if
+// its Origin is trusted or it echoes the CSRF cookie in HeaderName.
+// Requests without an auth cookie (bearer clients) are not checked.
+// TrustedOrigins default to the CORS allowed origins. Enabled defaults to
+// true.
+type CSRF struct {
+	Enabled        *bool    `yaml:"enabled"`
+	HeaderName     string   `yaml:"header_name" env-default:"X-CSRF-Token"`
+	TrustedOrigins []string `yaml:"trusted_origins"`
+}
+
+// Tracing configures the span exporter. SampleRatio is the share of new
+// traces that are sampled, it defaults to 1 and 0 samples none of them
+// (traces sampled by the caller are still recorded).
+type Tracing struct {
+	Exporter    string   `yaml:"exporter" env:"TRACING_EXPORTER" env-default:"none"`
+	Endpoint    string   `yaml:"endpoint" env:"TRACING_ENDPOINT"`
+	Insecure    bool     `yaml:"insecure"`
+	SampleRatio *float64 `yaml:"sample_ratio"`
+	ServiceName string   `yaml:"service_name" env-default:"api-gateway"`
+}
+
+// Ratio returns SampleRatio or its default.
+func (t Tracing) Ratio() float64 {
+	if t.SampleRatio != nil {
+		return *t.SampleRatio
+	}
+
+	return 1
+}
+
+// Backends returns the backend settings by the name of their config key.
+// The same names are used in health checks and client metrics.
+func (g GrpcClients) Backends() map[string]Backend {
+	return map[string]Backend{
+		"auth":         g.Auth,
+		"user":         g.User,
+		"matcher":      g.Matcher,
+		"file_storage": g.FileStorage,
+		"chat":         g.Chat,
+		"notification": g.Notification,
+	}
+}
+
+// Validate checks the settings that cannot be expressed with struct tags.
+func (c *Config) Validate() error {
+	const op = "config.Validate"
+
+	backends := c.GRPC_Clients.Backends()
+
+	var errs []error
+	for name, backend := range backends {
+		if err := backend.TLS.validate(c.Env, c.GRPC_Clients.AllowInsecure); err != nil {
+			errs = append(errs, fmt.Errorf("grpc_clients.%s.tls: %w", name, err))
+		}
+	}
+
+	for _, name := range c.Health.CriticalBackends {
+		if _, ok := backends[name]; !ok {
+			errs = append(errs, fmt.Errorf("health.critical_backends: unknown backend %q", name))
+		}
+	}
+
+	if c.PublicKey == "" && len(c.Keys.PublicKeys) == 0 && c.Keys.Dir == "" && c.Keys.JWKSURL == "" {
+		errs = append(errs, errors.New("keys: no public key, set PUBLIC_KEY or keys"))
+	}
+
+	if err := c.Cookies.validate(c.Env); err != nil {
+		errs = append(errs, fmt.Errorf("cookies: %w", err))
+	}
+
+	if err := c.HTTP_Server.TLS.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("http_server.tls: %w", err))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (t BackendTLS) validate(env string, allowInsecure bool) error {
+	switch t.Mode {
+	case TLSModeInsecure:
+		if env == EnvProd && !allowInsecure {
+			return errors.New("insecure mode is not allowed in prod, set allow_insecure to override")
+		}
+	case TLSModeTLS:
+	case TLSModeMTLS:
+		if t.CertFile == "" || t.KeyFile == "" {
+			return errors.New("mtls mode requires cert_file and key_file")
+		}
+	default:
+		return fmt.Errorf("unknown mode %q", t.Mode)
+	}
+
+	return nil
+}
+
+func (t ServerTLS) validate() error {
+	if !t.Enabled {
+		return nil
+	}
+
+	if t.CertFile == "" || t.KeyFile == "" {
+		return errors.New("cert_file and key_file are required")
+	}
+
+	if _, ok := tlsVersions[t.MinVersion]; !ok {
+		return fmt.Errorf("unsupported min_version %q", t.MinVersion)
+	}
+
+	return nil
+}
+
+// IsSecure returns Secure or its default for the environment.
+func (c Cookies) IsSecure(env string) bool {
+	if c.Secure != nil {
+		return *c.Secure
+	}
+
+	return env == EnvProd
+}
+
+// IsEnabled returns Enabled or its default.
+func (c CSRF) IsEnabled() bool {
+	if c.Enabled != nil {
+		return *c.Enabled
+	}
+
+	return true
+}
+
+func (c Cookies) validate(env string) error {
+	switch c.SameSite {
+	case "", SameSiteLax, SameSiteStrict:
+	case SameSiteNone:
+		if !c.IsSecure(env) {
+			return errors.New("same_site none requires secure cookies")
+		}
+	default:
+		return fmt.Errorf("unknown same_site %q", c.SameSite)
+	}
+
+	return nil
+}
@@ -0,0 +1,277 @@
+// Package jobs persists long-running background jobs of the gateway. Every
+// job is a JSON file in a directory, so progress survives restarts and an
+// interrupted or failed job can be resumed from its first unfinished step.
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound = errors.New("job not found")
+	// ErrNotFinished and ErrExpired are returned for artifacts of jobs that
+	// are still running or were finished too long ago.
+	ErrNotFinished = errors.New("job not finished")
+	ErrExpired     = errors.New("job expired")
+)
+
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusRunning Status = "running"
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed"
+	// StatusSkipped is a step that could not or did not need to be done.
+	StatusSkipped Status = "skipped"
+	// StatusCleanupPending is a step that left data in a backend which
+	// cannot remove it, recorded in Job.Data for a manual cleanup. A job
+	// with such a step ends in this status instead of StatusDone.
+	StatusCleanupPending Status = "cleanup_pending"
+)
+
+type Step struct {
+	Name      string    `json:"name"`
+	Status    Status    `json:"status"`
+	Message   string    `json:"message,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Finished reports whether the step must not run again.
+func (s *Step) Finished() bool {
+	return s.Status == StatusDone || s.Status == StatusSkipped || s.Status == StatusCleanupPending
+}
+
+type Job struct {
+	ID     string `json:"id"`
+	Kind   string `json:"kind"`
+	UserID string `json:"user_id"`
+	Status Status `json:"status"`
+	Steps  []Step `json:"steps"`
+	Error  string `json:"error,omitempty"`
+	// Data is state the steps hand over to each other.
+	Data      map[string]string `json:"data,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// Unfinished reports whether the job still has to be run or resumed.
+func (j *Job) Unfinished() bool {
+	return j.Status != StatusDone && j.Status != StatusCleanupPending
+}
+
+type Store struct {
+	dir string
+
+	mu sync.Mutex
+}
+
+func NewStore(dir string) (*Store, error) {
+	const op = "jobs.NewStore"
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Store{dir: dir}, nil
+}
+
+// Create saves a new pending job with the given steps.
+func (s *Store) Create(kind, userID string, steps []string) (*Job, error) {
+	now := time.Now().UTC()
+
+	job := &Job{
+		ID:        uuid.NewString(),
+		Kind:      kind,
+		UserID:    userID,
+		Status:    StatusPending,
+		Steps:     make([]Step, len(steps)),
+		Data:      make(map[string]string),
+		CreatedAt: now,
+	}
+	for i, name := range steps {
+		job.Steps[i] = Step{
+			Name:      name,
+			Status:    StatusPending,
+			UpdatedAt: now,
+		}
+	}
+
+	if err := s.Save(job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// Save writes the job atomically, readers never see a partial file.
+func (s *Store) Save(job *Job) error {
+	const op = "jobs.Store.Save"
+
+	job.UpdatedAt = time.Now().UTC()
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp, err := os.CreateTemp(s.dir, job.ID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path(job.ID)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Store) Get(id string) (*Job, error) {
+	const op = "jobs.Store.Get"
+
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrNotFound
+	}
+
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", op, id, err)
+	}
+	if job.Data == nil {
+		job.Data = make(map[string]string)
+	}
+
+	return &job, nil
+}
+
+// Latest returns the most recently created job of the kind for the user.
+func (s *Store) Latest(kind, userID string) (*Job, error) {
+	jobs, err := s.list(func(job *Job) bool {
+		return job.Kind == kind && job.UserID == userID
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *Job
+	for _, job := range jobs {
+		if latest == nil || job.CreatedAt.After(latest.CreatedAt) {
+			latest = job
+		}
+	}
+	if latest == nil {
+		return nil, ErrNotFound
+	}
+
+	return latest, nil
+}
+
+// Interrupted returns the jobs of the kind that were pending or running, for
+// example because the gateway was stopped in the middle of them.
+func (s *Store) Interrupted(kind string) ([]*Job, error) {
+	return s.list(func(job *Job) bool {
+		return job.Kind == kind && (job.Status == StatusPending || job.Status == StatusRunning)
+	})
+}
+
+// UpdatedBefore returns the jobs of the kind last updated before t.
+func (s *Store) UpdatedBefore(kind string, t time.Time) ([]*Job, error) {
+	return s.list(func(job *Job) bool {
+		return job.Kind == kind && job.UpdatedAt.Before(t)
+	})
+}
+
+// ArtifactDir is the directory for the files a job produces. It is created
+// on demand and removed together with the job.
+func (s *Store) ArtifactDir(id string) string {
+	return filepath.Join(s.dir, id)
+}
+
+// Delete removes the job and its artifacts.
+func (s *Store) Delete(id string) error {
+	const op = "jobs.Store.Delete"
+
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrNotFound
+	}
+
+	if err := os.RemoveAll(s.ArtifactDir(id)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// list reads every job. The store is meant for a handful of jobs per user,
+// so there is no index.
+func (s *Store) list(match func(*Job) bool) ([]*Job, error) {
+	const op = "jobs.Store.list"
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var jobs []*Job
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+
+		job, err := s.Get(id)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if match(job) {
+			jobs = append(jobs, job)
+		}
+	}
+
+	return jobs, nil
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
@@ -0,0 +1,227 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"api-gateway/internal/metrics"
+
+	"github.com/hesoyamTM/nbf-auth/pkg/logger"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StepFunc does one step of a job and returns StatusDone, StatusSkipped or
+// StatusCleanupPending with a message for the status endpoints. Steps must be idempotent, a step
+// is run again when the job is resumed after a failure or a restart.
+type StepFunc func(ctx context.Context, job *Job) (Status, string, error)
+
+// Runner runs the jobs of one kind in the background, one step after
+// another, and saves the progress after every step. A failed step stops
+// the job; resuming it starts from that step.
+type Runner struct {
+	store       *Store
+	kind        string
+	stepTimeout time.Duration
+
+	order    []string
+	steps    map[string]StepFunc
+	timeouts map[string]time.Duration
+
+	mu      sync.Mutex
+	running map[string]struct{}
+}
+
+func NewRunner(store *Store, kind string, stepTimeout time.Duration) *Runner {
+	return &Runner{
+		store:       store,
+		kind:        kind,
+		stepTimeout: stepTimeout,
+		steps:       make(map[string]StepFunc),
+		timeouts:    make(map[string]time.Duration),
+		running:     make(map[string]struct{}),
+	}
+}
+
+// Step appends a step. Steps run in the order they are added.
+func (r *Runner) Step(name string, fn StepFunc) {
+	r.order = append(r.order, name)
+	r.steps[name] = fn
+}
+
+// StepWithTimeout appends a step that runs for at most timeout instead of
+// the step timeout of the runner. A step that bounds its own calls passes
+// 0 to run without a deadline.
+func (r *Runner) StepWithTimeout(name string, timeout time.Duration, fn StepFunc) {
+	r.Step(name, fn)
+	r.timeouts[name] = timeout
+}
+
+// Start runs a job for the user. If reuse accepts the latest job of the
+// user, that job is returned instead and resumed if it is unfinished.
+// Otherwise a new job is created.
+func (r *Runner) Start(ctx context.Context, userID string, reuse func(*Job) bool) (*Job, error) {
+	const op = "jobs.Runner.Start"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, err := r.store.Latest(r.kind, userID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if job == nil || !reuse(job) {
+		job, err = r.store.Create(r.kind, userID, r.order)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if !job.Unfinished() {
+		return job, nil
+	}
+	if _, ok := r.running[job.ID]; ok {
+		return job, nil
+	}
+
+	if job.Status == StatusFailed {
+		job.Status = StatusPending
+		job.Error = ""
+		if err := r.store.Save(job); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	r.start(context.WithoutCancel(ctx), job.ID)
+
+	return job, nil
+}
+
+// Resume restarts the jobs interrupted by a restart of the gateway.
+func (r *Runner) Resume(ctx context.Context) {
+	log, err := logger.LoggerFromCtx(ctx)
+	if err != nil {
+		return
+	}
+
+	interrupted, err := r.store.Interrupted(r.kind)
+	if err != nil {
+		log.Error("Failed to list interrupted jobs", zap.String("kind", r.kind), zap.Error(err))
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, job := range interrupted {
+		if _, ok := r.running[job.ID]; ok {
+			continue
+		}
+
+		log.Info("Resuming job", zap.String("kind", r.kind), zap.String("job_id", job.ID), zap.String("user_id", job.UserID))
+		r.start(ctx, job.ID)
+	}
+}
+
+func (r *Runner) stepContext(ctx context.Context, name string) (context.Context, context.CancelFunc) {
+	timeout, ok := r.timeouts[name]
+	if !ok {
+		timeout = r.stepTimeout
+	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// start must be called with r.mu held.
+func (r *Runner) start(ctx context.Context, jobID string) {
+	r.running[jobID] = struct{}{}
+
+	go func() {
+		defer func() {
+			r.mu.Lock()
+			delete(r.running, jobID)
+			r.mu.Unlock()
+		}()
+
+		r.run(ctx, jobID)
+	}()
+}
+
+func (r *Runner) run(ctx context.Context, jobID string) {
+	log, err := logger.LoggerFromCtx(ctx)
+	if err != nil {
+		return
+	}
+	log = log.With(zap.String("kind", r.kind), zap.String("job_id", jobID))
+
+	job, err := r.store.Get(jobID)
+	if err != nil {
+		log.Error("Failed to load job", zap.Error(err))
+		return
+	}
+
+	job.Status = StatusRunning
+	if err := r.store.Save(job); err != nil {
+		log.Error("Failed to save job", zap.Error(err))
+		return
+	}
+
+	for i := range job.Steps {
+		step := &job.Steps[i]
+		if step.Finished() {
+			continue
+		}
+
+		stepCtx, cancel := r.stepContext(ctx, step.Name)
+		result, message, err := r.steps[step.Name](stepCtx, job)
+		cancel()
+
+		if status.Code(err) == codes.Unimplemented {
+			result, message, err = StatusSkipped, "not supported by the backend", nil
+		}
+		if err != nil {
+			result, message = StatusFailed, err.Error()
+		}
+
+		step.Status = result
+		step.Message = message
+		step.UpdatedAt = time.Now().UTC()
+		metrics.JobStepFinished(r.kind, step.Name, string(result))
+
+		if err != nil {
+			log.Error("Job step failed", zap.String("step", step.Name), zap.Error(err))
+
+			job.Status = StatusFailed
+			job.Error = fmt.Sprintf("step %s failed", step.Name)
+		}
+
+		if err := r.store.Save(job); err != nil {
+			log.Error("Failed to save job", zap.Error(err))
+			return
+		}
+		if job.Status == StatusFailed {
+			return
+		}
+	}
+
+	job.Status = StatusDone
+	for _, step := range job.Steps {
+		if step.Status == StatusCleanupPending {
+			job.Status = StatusCleanupPending
+		}
+	}
+	if err := r.store.Save(job); err != nil {
+		log.Error("Failed to save job", zap.Error(err))
+		return
+	}
+
+	log.Info("Job finished", zap.String("user_id", job.UserID), zap.String("status", string(job.Status)))
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	typeUnary  = "unary"
+	typeStream = "stream"
+)
+
+// UnaryClientInterceptor records latency and status code of unary calls to a backend.
+func UnaryClientInterceptor(backend string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		grpcClientHandling.
+			WithLabelValues(backend, method, typeUnary, status.Code(err).String()).
+			Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
+
+// StreamClientInterceptor records lifetime and final status code of streams to a backend.
+func StreamClientInterceptor(backend string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			grpcClientHandling.
+				WithLabelValues(backend, method, typeStream, status.Code(err).String()).
+				Observe(time.Since(start).Seconds())
+
+			return nil, err
+		}
+
+		return &monitoredStream{
+			ClientStream: stream,
+			backend:      backend,
+			method:       method,
+			start:        start,
+		}, nil
+	}
+}
+
+// monitoredStream observes the stream once, when it is finished.
+type monitoredStream struct {
+	grpc.ClientStream
+	backend string
+	method  string
+	start   time.Time
+	once    sync.Once
+}
+
+func (s *monitoredStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.finish(err)
+	}
+
+	return err
+}
+
+func (s *monitoredStream) finish(err error) {
+	s.once.Do(func() {
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+
+		grpcClientHandling.
+			WithLabelValues(s.backend, s.method, typeStream, status.Code(err).String()).
+			Observe(time.Since(s.start).Seconds())
+	})
+}
@@ -0,0 +1,93 @@
+// Package metrics provides Prometheus metrics of the gateway: HTTP routes,
+// backend gRPC calls, long-lived streaming connections and background jobs.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "gateway"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by route, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	grpcClientHandling = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "grpc_client_handling_seconds",
+		Help:      "Latency of outgoing gRPC calls by backend, method, call type and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"backend", "method", "type", "code"})
+
+	openStreams = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "open_streams",
+		Help:      "Number of open streaming connections (chat WebSockets and SSE subscribers).",
+	}, []string{"kind"})
+
+	presignedURLFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "presigned_url_failures_total",
+		Help:      "Number of failed presigned photo URL resolutions.",
+	}, []string{"operation"})
+
+	photoURLCache = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "photo_url_cache_total",
+		Help:      "Lookups of presigned photo URLs in the cache by result.",
+	}, []string{"result"})
+
+	jobSteps = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "job_steps_total",
+		Help:      "Executed steps of background jobs by kind, step and resulting status.",
+	}, []string{"kind", "step", "status"})
+)
+
+// Handler exposes the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// StreamOpened and StreamClosed track open streaming connections of a kind.
+func StreamOpened(kind string) {
+	openStreams.WithLabelValues(kind).Inc()
+}
+
+func StreamClosed(kind string) {
+	openStreams.WithLabelValues(kind).Dec()
+}
+
+// PresignedURLFailed counts a failed presigned URL resolution.
+func PresignedURLFailed(operation string) {
+	presignedURLFailures.WithLabelValues(operation).Inc()
+}
+
+// PhotoURLCacheLookup counts a lookup of a presigned URL in the cache.
+func PhotoURLCacheLookup(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+
+	photoURLCache.WithLabelValues(result).Inc()
+}
+
+// JobStepFinished counts an executed step of a background job.
+func JobStepFinished(kind, step, status string) {
+	jobSteps.WithLabelValues(kind, step, status).Inc()
+}
@@ -0,0 +1,213 @@
+// Package apierror provides a single error layer for HTTP handlers.
+// It maps gRPC status codes of backend services to HTTP statuses and
+// renders a structured JSON error body.
+package apierror
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StatusClientClosedRequest is used when the client has gone away before the response.
+const StatusClientClosedRequest = 499
+
+// Machine-readable error codes.
+const (
+	CodeBadRequest      = "bad_request"
+	CodeInvalidArgument = "invalid_argument"
+	CodeUnauthorized    = "unauthorized"
+	CodeForbidden       = "forbidden"
+	CodeUserBlocked     = "user_blocked"
+	CodeCSRF            = "csrf_failed"
+	CodeNotFound        = "not_found"
+	CodeExpired         = "expired"
+	CodeConflict        = "conflict"
+	CodeTooLarge        = "too_large"
+	CodeTooManyRequests = "too_many_requests"
+	CodeCanceled        = "canceled"
+	CodeInternal        = "internal"
+	CodeNotImplemented  = "not_implemented"
+	CodeUnavailable     = "unavailable"
+	CodeTimeout         = "timeout"
+)
+
+// FieldError describes a problem with a single request field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+} // @name FieldError
+
+// Error is the JSON body of every error response.
+type Error struct {
+	Status    int          `json:"-"`
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	RequestID string       `json:"request_id,omitempty"`
+	Fields    []FieldError `json:"fields,omitempty"`
+
+	// RetryAfter is sent in the Retry-After header when set.
+	RetryAfter time.Duration `json:"-"`
+} // @name Error
+
+func New(status int, code, message string) *Error {
+	return &Error{
+		Status:  status,
+		Code:    code,
+		Message: message,
+	}
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// WithFields returns a copy of the error with field errors attached.
+func (e *Error) WithFields(fields ...FieldError) *Error {
+	cp := *e
+	cp.Fields = append(append([]FieldError(nil), e.Fields...), fields...)
+
+	return &cp
+}
+
+// FromGRPC converts an error returned by a backend client into an API error.
+// Client-side (4xx) errors keep the backend message, server-side errors use
+// the given fallback message so that internals are not leaked.
+func FromGRPC(err error, fallback string) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	st, ok := grpcStatus(err)
+	if !ok {
+		return New(http.StatusInternalServerError, CodeInternal, fallback)
+	}
+
+	httpStatus, code := fromCode(st.Code())
+
+	message := fallback
+	if httpStatus < http.StatusInternalServerError && st.Message() != "" {
+		message = st.Message()
+	}
+
+	apiErr = New(httpStatus, code, message).WithFields(fieldViolations(st)...)
+	apiErr.RetryAfter, _ = RetryAfter(err)
+
+	return apiErr
+}
+
+// RetryAfter reports whether the error was returned without calling the
+// backend, because it is known to be unavailable for the given time.
+func RetryAfter(err error) (time.Duration, bool) {
+	var ra interface{ RetryAfter() time.Duration }
+	if errors.As(err, &ra) {
+		return ra.RetryAfter(), true
+	}
+
+	return 0, false
+}
+
+// Write renders the error as JSON with the request ID of the current request.
+func Write(w http.ResponseWriter, r *http.Request, e *Error) {
+	body := *e
+	body.RequestID = middleware.GetReqID(r.Context())
+
+	if body.RetryAfter > 0 {
+		seconds := int(math.Ceil(body.RetryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	}
+
+	render.Status(r, body.Status)
+	render.JSON(w, r, &body)
+}
+
+// GRPC renders an error returned by a backend client.
+func GRPC(w http.ResponseWriter, r *http.Request, err error, fallback string) {
+	Write(w, r, FromGRPC(err, fallback))
+}
+
+func BadRequest(w http.ResponseWriter, r *http.Request, message string, fields ...FieldError) {
+	Write(w, r, New(http.StatusBadRequest, CodeBadRequest, message).WithFields(fields...))
+}
+
+// Unprocessable reports a well-formed request with invalid values.
+func Unprocessable(w http.ResponseWriter, r *http.Request, message string, fields ...FieldError) {
+	Write(w, r, New(http.StatusUnprocessableEntity, CodeInvalidArgument, message).WithFields(fields...))
+}
+
+func Unauthorized(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, New(http.StatusUnauthorized, CodeUnauthorized, "Unauthorized"))
+}
+
+func Forbidden(w http.ResponseWriter, r *http.Request, message string) {
+	Write(w, r, New(http.StatusForbidden, CodeForbidden, message))
+}
+
+func Internal(w http.ResponseWriter, r *http.Request, message string) {
+	Write(w, r, New(http.StatusInternalServerError, CodeInternal, message))
+}
+
+func grpcStatus(err error) (*status.Status, bool) {
+	var se interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &se) {
+		return se.GRPCStatus(), true
+	}
+
+	return nil, false
+}
+
+func fromCode(code codes.Code) (int, string) {
+	switch code {
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusUnprocessableEntity, CodeInvalidArgument
+	case codes.NotFound:
+		return http.StatusNotFound, CodeNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict, CodeConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden, CodeForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized, CodeUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests, CodeTooManyRequests
+	case codes.Canceled:
+		return StatusClientClosedRequest, CodeCanceled
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout, CodeTimeout
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable, CodeUnavailable
+	case codes.Unimplemented:
+		return http.StatusNotImplemented, CodeNotImplemented
+	default:
+		return http.StatusInternalServerError, CodeInternal
+	}
+}
+
+func fieldViolations(st *status.Status) []FieldError {
+	var fields []FieldError
+
+	for _, detail := range st.Details() {
+		badRequest, ok := detail.(*errdetails.BadRequest)
+		if !ok {
+			continue
+		}
+
+		for _, violation := range badRequest.GetFieldViolations() {
+			fields = append(fields, FieldError{
+				Field:   violation.GetField(),
+				Message: violation.GetDescription(),
+			})
+		}
+	}
+
+	return fields
+}
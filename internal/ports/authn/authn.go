@@ -0,0 +1,191 @@
+// Package authn authenticates HTTP requests by an ES256 access token taken
+// from the Authorization header (bearer clients) or the access_token cookie
+// (browsers). The identity is stored under the context keys of nbf-auth, so
+// handlers do not depend on where the token came from.
+package authn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"api-gateway/internal/ports/apierror"
+	"api-gateway/internal/ports/cookies"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	authorization "github.com/hesoyamTM/nbf-auth/pkg/auth"
+	"github.com/hesoyamTM/nbf-auth/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type ctxKey string
+
+const methodKey ctxKey = "authn_method"
+
+// Method is the way a request was authenticated.
+type Method string
+
+const (
+	MethodCookie Method = "cookie"
+	MethodBearer Method = "bearer"
+)
+
+var (
+	ErrNoToken      = errors.New("no access token")
+	ErrInvalidToken = errors.New("invalid access token")
+)
+
+// Claims of the access token issued by the auth service.
+type Claims struct {
+	UID       string
+	Name      string
+	Surname   string
+	ExpiresAt time.Time
+}
+
+type Authenticator struct {
+	keys      *KeySet
+	refresher *Refresher
+}
+
+// New returns an Authenticator. If refresher is not nil, browsers with an
+// expired access token are refreshed inline instead of getting 401.
+func New(keys *KeySet, refresher *Refresher) *Authenticator {
+	return &Authenticator{
+		keys:      keys,
+		refresher: refresher,
+	}
+}
+
+// Middleware rejects requests without a valid access token with 401.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log, err := logger.LoggerFromCtx(r.Context())
+		if err != nil {
+			apierror.Internal(w, r, "Internal error")
+			return
+		}
+
+		var claims *Claims
+		token, method, err := TokenFromRequest(r)
+		if err == nil {
+			claims, err = a.Parse(token)
+		}
+
+		if err != nil && method == MethodCookie && a.refresher != nil && canRefresh(err) {
+			claims, err = a.refresh(w, r)
+			if err != nil {
+				log.Info("Failed to refresh access token", zap.Error(err))
+
+				if !isRejected(err) {
+					apierror.GRPC(w, r, err, "Failed to refresh token")
+					return
+				}
+
+				a.refresher.cookies.ClearTokens(w)
+			}
+		}
+
+		if err != nil {
+			log.Info("Invalid access token", zap.Error(err), zap.String("method", string(method)))
+			apierror.Unauthorized(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithClaims(r.Context(), claims, method)))
+	})
+}
+
+func (a *Authenticator) refresh(w http.ResponseWriter, r *http.Request) (*Claims, error) {
+	tokens, err := a.refresher.Refresh(w, r)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.Parse(tokens.AccessToken)
+}
+
+// Parse verifies the token signature with the key of its kid and the
+// expiration.
+func (a *Authenticator) Parse(token string) (*Claims, error) {
+	const op = "authn.Parse"
+
+	parsed, err := jwt.Parse(token, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+
+		return a.keys.Key(kid)
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	claims, err := claimsFromMap(parsed.Claims.(jwt.MapClaims))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return claims, nil
+}
+
+func claimsFromMap(m jwt.MapClaims) (*Claims, error) {
+	uid, _ := m["uid"].(string)
+	if _, err := uuid.Parse(uid); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	exp, ok := m["exp"].(float64)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	name, _ := m["name"].(string)
+	surname, _ := m["surname"].(string)
+
+	return &Claims{
+		UID:       uid,
+		Name:      name,
+		Surname:   surname,
+		ExpiresAt: time.Unix(int64(exp), 0),
+	}, nil
+}
+
+// TokenFromRequest returns the bearer token, or the access_token cookie if
+// there is no Authorization header.
+func TokenFromRequest(r *http.Request) (string, Method, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			return "", MethodBearer, ErrNoToken
+		}
+
+		return strings.TrimSpace(token), MethodBearer, nil
+	}
+
+	cookie, err := r.Cookie(cookies.AccessToken)
+	if err != nil || cookie.Value == "" {
+		return "", MethodCookie, ErrNoToken
+	}
+
+	return cookie.Value, MethodCookie, nil
+}
+
+// WithClaims stores the identity in the context.
+func WithClaims(ctx context.Context, claims *Claims, method Method) context.Context {
+	ctx = context.WithValue(ctx, authorization.UID, claims.UID)
+	ctx = context.WithValue(ctx, authorization.NAME, claims.Name)
+	ctx = context.WithValue(ctx, authorization.SURNAME, claims.Surname)
+	ctx = context.WithValue(ctx, methodKey, method)
+
+	return ctx
+}
+
+// MethodFromCtx returns how the request was authenticated.
+func MethodFromCtx(ctx context.Context) (Method, bool) {
+	method, ok := ctx.Value(methodKey).(Method)
+
+	return method, ok
+}
@@ -0,0 +1,322 @@
+package authn
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"api-gateway/internal/config"
+
+	"github.com/hesoyamTM/nbf-auth/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// an unknown kid reloads the dynamic keys at most this often
+const unknownKeyReloadInterval = 30 * time.Second
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// KeySet holds the public keys of the auth service by kid. Static keys come
+// from the config, the key directory and the JWKS URL are reloaded.
+type KeySet struct {
+	static  map[string]*ecdsa.PublicKey
+	dir     string
+	jwksURL string
+	client  *http.Client
+
+	reloadMu sync.Mutex
+
+	mu       sync.RWMutex
+	keys     map[string]*ecdsa.PublicKey
+	loadedAt time.Time
+}
+
+// NewKeySet loads every configured source once. publicKey is the legacy
+// PUBLIC_KEY, it gets the empty kid.
+func NewKeySet(ctx context.Context, cfg config.Keys, publicKey string) (*KeySet, error) {
+	const op = "authn.NewKeySet"
+
+	k := &KeySet{
+		static:  make(map[string]*ecdsa.PublicKey),
+		dir:     cfg.Dir,
+		jwksURL: cfg.JWKSURL,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if publicKey != "" {
+		key, err := decodePEM([]byte(publicKey))
+		if err != nil {
+			return nil, fmt.Errorf("%s: PUBLIC_KEY: %w", op, err)
+		}
+		k.static[""] = key
+	}
+
+	for _, pk := range cfg.PublicKeys {
+		key, err := decodePEM([]byte(pk.PEM))
+		if err != nil {
+			return nil, fmt.Errorf("%s: key %q: %w", op, pk.KID, err)
+		}
+		k.static[pk.KID] = key
+	}
+
+	if err := k.Reload(ctx); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return k, nil
+}
+
+// Key returns the key for kid. A token without kid is verified with the
+// only key of the set, or with PUBLIC_KEY.
+func (k *KeySet) Key(kid string) (*ecdsa.PublicKey, error) {
+	if key, ok := k.lookup(kid); ok {
+		return key, nil
+	}
+
+	// the token may be signed with a key published after the last reload
+	if k.dynamic() && kid != "" && k.reloadStale() {
+		if key, ok := k.lookup(kid); ok {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: kid %q", ErrUnknownKey, kid)
+}
+
+// reloadStale reloads the keys unless they were loaded recently and reports
+// whether they were reloaded.
+func (k *KeySet) reloadStale() bool {
+	k.reloadMu.Lock()
+	defer k.reloadMu.Unlock()
+
+	k.mu.RLock()
+	stale := time.Since(k.loadedAt) > unknownKeyReloadInterval
+	k.mu.RUnlock()
+
+	if !stale {
+		return false
+	}
+
+	return k.reload(context.Background()) == nil
+}
+
+func (k *KeySet) lookup(kid string) (*ecdsa.PublicKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if key, ok := k.keys[kid]; ok {
+		return key, true
+	}
+
+	if kid == "" && len(k.keys) == 1 {
+		for _, key := range k.keys {
+			return key, true
+		}
+	}
+
+	return nil, false
+}
+
+func (k *KeySet) dynamic() bool {
+	return k.dir != "" || k.jwksURL != ""
+}
+
+// Reload reads the key directory and the JWKS. The previous keys are kept
+// if any source fails.
+func (k *KeySet) Reload(ctx context.Context) error {
+	k.reloadMu.Lock()
+	defer k.reloadMu.Unlock()
+
+	return k.reload(ctx)
+}
+
+func (k *KeySet) reload(ctx context.Context) error {
+	const op = "authn.KeySet.Reload"
+
+	keys := make(map[string]*ecdsa.PublicKey, len(k.static))
+	for kid, key := range k.static {
+		keys[kid] = key
+	}
+
+	if k.dir != "" {
+		if err := loadDir(k.dir, keys); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if k.jwksURL != "" {
+		if err := k.loadJWKS(ctx, keys); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if len(keys) == 0 {
+		return fmt.Errorf("%s: no public keys", op)
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.loadedAt = time.Now()
+	k.mu.Unlock()
+
+	return nil
+}
+
+// Watch reloads the dynamic keys every interval until ctx is done.
+func (k *KeySet) Watch(ctx context.Context, interval time.Duration) {
+	log, err := logger.LoggerFromCtx(ctx)
+	if err != nil {
+		panic(err)
+	}
+
+	if !k.dynamic() {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := k.Reload(ctx); err != nil {
+			log.Warn("failed to reload public keys", zap.Error(err))
+		}
+	}
+}
+
+func loadDir(dir string, keys map[string]*ecdsa.PublicKey) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		key, err := decodePEM(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+
+		keys[strings.TrimSuffix(filepath.Base(file), ".pem")] = key
+	}
+
+	return nil
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// loadJWKS adds the P-256 signing keys of the JWKS, other keys are skipped.
+func (k *KeySet) loadJWKS(ctx context.Context, keys map[string]*ecdsa.PublicKey) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.jwksURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("jwks: unexpected status %s", resp.Status)
+	}
+
+	var set jwks
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("jwks: %w", err)
+	}
+
+	for _, jk := range set.Keys {
+		if jk.Kty != "EC" || jk.Crv != "P-256" || (jk.Use != "" && jk.Use != "sig") || (jk.Alg != "" && jk.Alg != "ES256") {
+			continue
+		}
+
+		key, err := jk.publicKey()
+		if err != nil {
+			return fmt.Errorf("jwks: key %q: %w", jk.Kid, err)
+		}
+
+		keys[jk.Kid] = key
+	}
+
+	return nil
+}
+
+func (jk jwk) publicKey() (*ecdsa.PublicKey, error) {
+	x, err := base64.RawURLEncoding.DecodeString(jk.X)
+	if err != nil {
+		return nil, err
+	}
+
+	y, err := base64.RawURLEncoding.DecodeString(jk.Y)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}
+
+	// rejects points that are not on the curve
+	if _, err := key.ECDH(); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func decodePEM(data []byte) (*ecdsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block")
+	}
+
+	generic, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := generic.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("not an ECDSA public key")
+	}
+
+	return key, nil
+}
@@ -0,0 +1,266 @@
+package authn
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"api-gateway/internal/config"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+func TestKeySetKey(t *testing.T) {
+	k1, k2 := newKey(t, elliptic.P256()), newKey(t, elliptic.P256())
+
+	tests := []struct {
+		name      string
+		publicKey string
+		keys      []config.PublicKey
+		kid       string
+		want      *ecdsa.PrivateKey
+	}{
+		{name: "known kid", keys: []config.PublicKey{{KID: "k1", PEM: encodePEM(t, k1)}, {KID: "k2", PEM: encodePEM(t, k2)}}, kid: "k2", want: k2},
+		{name: "unknown kid", keys: []config.PublicKey{{KID: "k1", PEM: encodePEM(t, k1)}}, kid: "k2"},
+		{name: "no kid with one key", keys: []config.PublicKey{{KID: "k1", PEM: encodePEM(t, k1)}}, kid: "", want: k1},
+		{name: "no kid with several keys", keys: []config.PublicKey{{KID: "k1", PEM: encodePEM(t, k1)}, {KID: "k2", PEM: encodePEM(t, k2)}}, kid: ""},
+		{name: "no kid with PUBLIC_KEY", publicKey: encodePEM(t, k1), keys: []config.PublicKey{{KID: "k2", PEM: encodePEM(t, k2)}}, kid: "", want: k1},
+		{name: "kid does not fall back to PUBLIC_KEY", publicKey: encodePEM(t, k1), kid: "k2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := NewKeySet(context.Background(), config.Keys{PublicKeys: tt.keys}, tt.publicKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := keys.Key(tt.kid)
+			if tt.want == nil {
+				if !errors.Is(err, ErrUnknownKey) {
+					t.Fatalf("Key(%q) error = %v, want ErrUnknownKey", tt.kid, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Key(%q) error = %v", tt.kid, err)
+			}
+			if !got.Equal(&tt.want.PublicKey) {
+				t.Fatalf("Key(%q) returned another key", tt.kid)
+			}
+		})
+	}
+}
+
+func TestKeySetReloadsUnknownKidAtMostEveryInterval(t *testing.T) {
+	dir := t.TempDir()
+	writePEM(t, dir, "k1", newKey(t, elliptic.P256()))
+
+	keys, err := NewKeySet(context.Background(), config.Keys{Dir: dir}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := keys.Key("k2"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Key(k2) error = %v, want ErrUnknownKey", err)
+	}
+
+	// published right after the last reload
+	k2 := newKey(t, elliptic.P256())
+	writePEM(t, dir, "k2", k2)
+
+	if _, err := keys.Key("k2"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Key(k2) error = %v, want ErrUnknownKey until the interval has passed", err)
+	}
+
+	keys.mu.Lock()
+	keys.loadedAt = time.Now().Add(-unknownKeyReloadInterval - time.Second)
+	keys.mu.Unlock()
+
+	got, err := keys.Key("k2")
+	if err != nil {
+		t.Fatalf("Key(k2) error = %v after the interval", err)
+	}
+	if !got.Equal(&k2.PublicKey) {
+		t.Fatal("Key(k2) returned another key")
+	}
+}
+
+func TestKeySetThrottlesJWKSRequests(t *testing.T) {
+	k1 := toJWK("k1", newKey(t, elliptic.P256()))
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		json.NewEncoder(w).Encode(jwks{Keys: []jwk{k1}})
+	}))
+	defer server.Close()
+
+	keys, err := NewKeySet(context.Background(), config.Keys{JWKSURL: server.URL}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if _, err := keys.Key("unknown"); !errors.Is(err, ErrUnknownKey) {
+			t.Fatalf("Key(unknown) error = %v, want ErrUnknownKey", err)
+		}
+	}
+
+	if got := requests.Load(); got != 1 {
+		t.Fatalf("JWKS requests = %d, want 1", got)
+	}
+}
+
+func TestKeySetSkipsJWKSKeysOfOtherAlgorithms(t *testing.T) {
+	es256 := toJWK("es256", newKey(t, elliptic.P256()))
+	p384 := toJWK("p384", newKey(t, elliptic.P384()))
+	p384.Crv = "P-384"
+	rs256 := toJWK("rs256", newKey(t, elliptic.P256()))
+	rs256.Alg = "RS256"
+	rsa := toJWK("rsa", newKey(t, elliptic.P256()))
+	rsa.Kty = "RSA"
+	enc := toJWK("enc", newKey(t, elliptic.P256()))
+	enc.Use = "enc"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jwks{Keys: []jwk{es256, p384, rs256, rsa, enc}})
+	}))
+	defer server.Close()
+
+	keys, err := NewKeySet(context.Background(), config.Keys{JWKSURL: server.URL}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := keys.Key("es256"); err != nil {
+		t.Fatalf("Key(es256) error = %v", err)
+	}
+	for _, kid := range []string{"p384", "rs256", "rsa", "enc"} {
+		if _, err := keys.Key(kid); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("Key(%s) error = %v, want ErrUnknownKey", kid, err)
+		}
+	}
+}
+
+func TestAuthenticatorParseChecksKeyAndAlgorithm(t *testing.T) {
+	k1 := newKey(t, elliptic.P256())
+	other := newKey(t, elliptic.P256())
+	p384 := newKey(t, elliptic.P384())
+
+	keys, err := NewKeySet(context.Background(), config.Keys{
+		PublicKeys: []config.PublicKey{{KID: "k1", PEM: encodePEM(t, k1)}},
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := New(keys, nil)
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "ES256 with the key of kid", token: sign(t, jwt.SigningMethodES256, "k1", k1)},
+		{name: "unknown kid", token: sign(t, jwt.SigningMethodES256, "k2", k1), wantErr: ErrUnknownKey},
+		{name: "signed with another key", token: sign(t, jwt.SigningMethodES256, "k1", other), wantErr: jwt.ErrECDSAVerification},
+		{name: "ES384", token: sign(t, jwt.SigningMethodES384, "k1", p384), wantErr: jwt.ErrTokenSignatureInvalid},
+		{name: "HS256 with the public key as secret", token: sign(t, jwt.SigningMethodHS256, "k1", []byte(encodePEM(t, k1))), wantErr: jwt.ErrTokenSignatureInvalid},
+		{name: "none", token: sign(t, jwt.SigningMethodNone, "k1", jwt.UnsafeAllowNoneSignatureType), wantErr: jwt.ErrTokenSignatureInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := auth.Parse(tt.token)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Parse() error = %v", err)
+				}
+				if claims.UID == "" {
+					t.Fatal("Parse() returned no uid")
+				}
+				return
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Parse() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func newKey(t *testing.T, curve elliptic.Curve) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func encodePEM(t *testing.T, key *ecdsa.PrivateKey) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func writePEM(t *testing.T, dir, kid string, key *ecdsa.PrivateKey) {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), []byte(encodePEM(t, key)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func toJWK(kid string, key *ecdsa.PrivateKey) jwk {
+	size := (key.Curve.Params().BitSize + 7) / 8
+
+	return jwk{
+		Kty: "EC",
+		Kid: kid,
+		Use: "sig",
+		Alg: "ES256",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size))),
+		Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size))),
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, jwt.MapClaims{
+		"uid": uuid.NewString(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["kid"] = kid
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed
+}
@@ -0,0 +1,151 @@
+package authn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"api-gateway/internal/ports/cookies"
+	"api-gateway/internal/ports/handlers/auth_handler"
+
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type TokenRefresher interface {
+	RefreshToken(ctx context.Context, token string) (*auth_handler.Tokens, error)
+}
+
+type refreshResult struct {
+	tokens    *auth_handler.Tokens
+	expiresAt time.Time
+}
+
+// Refresher exchanges the refresh_token cookie for new tokens when the
+// access token of a browser has expired. Concurrent refreshes of the same
+// token share one upstream call, and the result is kept for a short time,
+// so that requests which still carry the rotated refresh token get the
+// same tokens instead of failing.
+type Refresher struct {
+	client  TokenRefresher
+	cookies *cookies.Issuer
+	ttl     time.Duration
+
+	group singleflight.Group
+
+	mu      sync.Mutex
+	results map[string]refreshResult
+}
+
+func NewRefresher(client TokenRefresher, cookieIssuer *cookies.Issuer, resultTTL time.Duration) *Refresher {
+	return &Refresher{
+		client:  client,
+		cookies: cookieIssuer,
+		ttl:     resultTTL,
+		results: make(map[string]refreshResult),
+	}
+}
+
+// Refresh returns new tokens for the refresh_token cookie of the request
+// and sets them as cookies on the response.
+func (f *Refresher) Refresh(w http.ResponseWriter, r *http.Request) (*auth_handler.Tokens, error) {
+	const op = "authn.Refresher.Refresh"
+
+	cookie, err := r.Cookie(cookies.RefreshToken)
+	if err != nil || cookie.Value == "" {
+		return nil, fmt.Errorf("%s: %w", op, ErrNoToken)
+	}
+
+	tokens, err := f.refresh(r.Context(), cookie.Value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	f.cookies.SetTokens(w, tokens.AccessToken, tokens.Access_expire_at, tokens.RefreshToken, tokens.Refresh_expire_at)
+
+	return tokens, nil
+}
+
+func (f *Refresher) refresh(ctx context.Context, refreshToken string) (*auth_handler.Tokens, error) {
+	if tokens, ok := f.cached(refreshToken); ok {
+		return tokens, nil
+	}
+
+	v, err, _ := f.group.Do(refreshToken, func() (any, error) {
+		if tokens, ok := f.cached(refreshToken); ok {
+			return tokens, nil
+		}
+
+		// the call is shared, one canceled request must not fail the others
+		tokens, err := f.client.RefreshToken(context.WithoutCancel(ctx), refreshToken)
+		if err != nil {
+			return nil, err
+		}
+
+		f.store(refreshToken, tokens)
+
+		return tokens, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*auth_handler.Tokens), nil
+}
+
+func (f *Refresher) cached(refreshToken string) (*auth_handler.Tokens, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	result, ok := f.results[refreshToken]
+	if !ok || time.Now().After(result.expiresAt) {
+		return nil, false
+	}
+
+	return result.tokens, true
+}
+
+func (f *Refresher) store(refreshToken string, tokens *auth_handler.Tokens) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	for token, result := range f.results {
+		if now.After(result.expiresAt) {
+			delete(f.results, token)
+		}
+	}
+
+	f.results[refreshToken] = refreshResult{
+		tokens:    tokens,
+		expiresAt: now.Add(f.ttl),
+	}
+}
+
+// canRefresh reports whether the access token is missing or expired, as
+// opposed to forged or malformed.
+func canRefresh(err error) bool {
+	return errors.Is(err, ErrNoToken) || errors.Is(err, jwt.ErrTokenExpired)
+}
+
+// isRejected reports whether the refresh token itself was refused, so the
+// token cookies are of no use anymore. Other errors of the auth service are
+// reported as they are.
+func isRejected(err error) bool {
+	st, ok := status.FromError(err)
+	if !ok {
+		return true
+	}
+
+	switch st.Code() {
+	case codes.Unauthenticated, codes.InvalidArgument, codes.NotFound, codes.PermissionDenied:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,34 @@
+// Package authz derives the acting user of a request from the access token.
+// Identifiers supplied by the client in paths or bodies are only accepted
+// when they match the token.
+package authz
+
+import (
+	"net/http"
+
+	"api-gateway/internal/ports/apierror"
+
+	authorization "github.com/hesoyamTM/nbf-auth/pkg/auth"
+)
+
+// Actor returns the user ID of the access token.
+func Actor(r *http.Request) (string, bool) {
+	uid, ok := r.Context().Value(authorization.UID).(string)
+
+	return uid, ok && uid != ""
+}
+
+// Resolve returns the acting user. The claimed ID may be empty, otherwise it
+// must belong to the token owner.
+func Resolve(r *http.Request, claimed string) (string, *apierror.Error) {
+	uid, ok := Actor(r)
+	if !ok {
+		return "", apierror.New(http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+	}
+
+	if claimed != "" && claimed != uid {
+		return "", apierror.New(http.StatusForbidden, apierror.CodeForbidden, "Acting on behalf of another user is not allowed")
+	}
+
+	return uid, nil
+}
@@ -0,0 +1,86 @@
+// Package cookies issues every cookie of the gateway with the same
+// configured policy: domain, path, Secure and SameSite.
+package cookies
+
+import (
+	"net/http"
+	"time"
+
+	"api-gateway/internal/config"
+)
+
+const (
+	AccessToken  = "access_token"
+	RefreshToken = "refresh_token"
+	OAuthState   = "oauth_state"
+	CSRFToken    = "csrf_token"
+)
+
+type Issuer struct {
+	domain   string
+	path     string
+	secure   bool
+	sameSite http.SameSite
+}
+
+func NewIssuer(cfg *config.Config) *Issuer {
+	domain := cfg.Cookies.Domain
+	if domain == "" {
+		domain = cfg.Domain
+	}
+
+	sameSite := http.SameSiteLaxMode
+	switch cfg.Cookies.SameSite {
+	case config.SameSiteStrict:
+		sameSite = http.SameSiteStrictMode
+	case config.SameSiteNone:
+		sameSite = http.SameSiteNoneMode
+	}
+
+	return &Issuer{
+		domain:   domain,
+		path:     cfg.Cookies.Path,
+		secure:   cfg.Cookies.IsSecure(cfg.Env),
+		sameSite: sameSite,
+	}
+}
+
+// Set sets an HttpOnly cookie.
+func (i *Issuer) Set(w http.ResponseWriter, name, value string, expires time.Time) {
+	http.SetCookie(w, i.cookie(name, value, expires, true))
+}
+
+// SetReadable sets a cookie that scripts of the frontend can read.
+func (i *Issuer) SetReadable(w http.ResponseWriter, name, value string, expires time.Time) {
+	http.SetCookie(w, i.cookie(name, value, expires, false))
+}
+
+// Clear removes a cookie.
+func (i *Issuer) Clear(w http.ResponseWriter, name string) {
+	http.SetCookie(w, i.cookie(name, "", time.Unix(0, 0), true))
+}
+
+// SetTokens sets the access and refresh token cookies.
+func (i *Issuer) SetTokens(w http.ResponseWriter, accessToken string, accessExpireAt time.Time, refreshToken string, refreshExpireAt time.Time) {
+	i.Set(w, AccessToken, accessToken, accessExpireAt)
+	i.Set(w, RefreshToken, refreshToken, refreshExpireAt)
+}
+
+// ClearTokens removes the access and refresh token cookies.
+func (i *Issuer) ClearTokens(w http.ResponseWriter) {
+	i.Clear(w, AccessToken)
+	i.Clear(w, RefreshToken)
+}
+
+func (i *Issuer) cookie(name, value string, expires time.Time, httpOnly bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Domain:   i.domain,
+		Path:     i.path,
+		Expires:  expires,
+		Secure:   i.secure,
+		HttpOnly: httpOnly,
+		SameSite: i.sameSite,
+	}
+}
@@ -0,0 +1,266 @@
+// Package deletion deletes a user account across the backend services as a
+// saga of idempotent steps persisted in the job store.
+package deletion
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-gateway/internal/jobs"
+	"api-gateway/internal/models"
+	"api-gateway/internal/ports/handlers/matcher_handler"
+	"api-gateway/internal/ports/handlers/user_handler"
+	"api-gateway/internal/ports/streams"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const Kind = "account_deletion"
+
+// Steps of the account deletion, in execution order. Photos are collected
+// while the profile and the form still exist, the user is deleted once
+// nothing refers to it anymore and the streams are closed last.
+const (
+	stepPhotos       = "photos"
+	stepJoinRequests = "join_requests"
+	stepChats        = "chats"
+	stepGroup        = "group"
+	stepForm         = "form"
+	stepUser         = "user"
+	stepSessions     = "sessions"
+)
+
+type UserClient interface {
+	GetUser(ctx context.Context, uid string) (*user_handler.User, error)
+	DeleteUser(ctx context.Context, uid string) error
+}
+
+type MatcherClient interface {
+	GetFormByUser(ctx context.Context, uid string) (*matcher_handler.Form, error)
+	DeleteForm(ctx context.Context, uid string) error
+	GetGroupByUser(ctx context.Context, uid string) (*matcher_handler.Group, error)
+	LeaveGroup(ctx context.Context, uid string) error
+	DeleteGroup(ctx context.Context, oid string) error
+	GetRequestsByUserId(ctx context.Context, uid string) ([]*matcher_handler.GroupRequest, error)
+}
+
+type ChatClient interface {
+	GetChatList(ctx context.Context, userID string) ([]models.Chat, error)
+}
+
+type SessionRevoker interface {
+	Logout(ctx context.Context, refreshToken string) error
+}
+
+// Saga deletes an account across the backends as a sequence of idempotent
+// steps. A failed deletion is resumed from the failed step when the user
+// asks again, an interrupted one when the gateway starts.
+type Saga struct {
+	store    *jobs.Store
+	runner   *jobs.Runner
+	users    UserClient
+	matcher  MatcherClient
+	chats    ChatClient
+	sessions SessionRevoker
+	streams  *streams.Registry
+}
+
+func NewSaga(
+	store *jobs.Store,
+	users UserClient,
+	matcher MatcherClient,
+	chats ChatClient,
+	sessions SessionRevoker,
+	streamRegistry *streams.Registry,
+	stepTimeout time.Duration,
+) *Saga {
+	d := &Saga{
+		store:    store,
+		runner:   jobs.NewRunner(store, Kind, stepTimeout),
+		users:    users,
+		matcher:  matcher,
+		chats:    chats,
+		sessions: sessions,
+		streams:  streamRegistry,
+	}
+
+	d.runner.Step(stepPhotos, d.requestPhotoDeletion)
+	d.runner.Step(stepJoinRequests, d.withdrawJoinRequests)
+	d.runner.Step(stepChats, d.recordChats)
+	d.runner.Step(stepGroup, d.leaveGroup)
+	d.runner.Step(stepForm, d.deleteForm)
+	d.runner.Step(stepUser, d.deleteUser)
+	d.runner.Step(stepSessions, d.closeStreams)
+
+	return d
+}
+
+// Start starts the deletion of the account, or resumes a failed or
+// interrupted one, and returns its current state. The steps run in the
+// background. A finished deletion is returned as is.
+//
+// refreshToken is the session that asks for the deletion, it may be empty.
+// It is logged out before the deletion starts, so the token is never kept
+// in the job store. The access token stays valid until it expires, long
+// enough to follow the status.
+func (d *Saga) Start(ctx context.Context, uid, refreshToken string) (*jobs.Job, error) {
+	const op = "deletion.Saga.Start"
+
+	if refreshToken != "" {
+		err := d.sessions.Logout(ctx, refreshToken)
+		if err != nil && !isNotFound(err) && status.Code(err) != codes.Unauthenticated {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return d.runner.Start(ctx, uid, func(*jobs.Job) bool {
+		return true
+	})
+}
+
+// Status returns the latest deletion of the account.
+func (d *Saga) Status(uid string) (*jobs.Job, error) {
+	return d.store.Latest(Kind, uid)
+}
+
+// Resume restarts the deletions interrupted by a restart of the gateway.
+func (d *Saga) Resume(ctx context.Context) {
+	d.runner.Resume(ctx)
+}
+
+// requestPhotoDeletion records the photos of the profile and the form. The
+// file storage has no delete call yet, so they are left for a manual
+// cleanup and the deletion ends as cleanup pending.
+func (d *Saga) requestPhotoDeletion(ctx context.Context, job *jobs.Job) (jobs.Status, string, error) {
+	var photoIDs []string
+
+	user, err := d.users.GetUser(ctx, job.UserID)
+	if err != nil && !isNotFound(err) {
+		return "", "", err
+	}
+	if user != nil && user.Avatar != "" {
+		photoIDs = append(photoIDs, user.Avatar)
+	}
+
+	form, err := d.matcher.GetFormByUser(ctx, job.UserID)
+	if err != nil && !isNotFound(err) {
+		return "", "", err
+	}
+	if form != nil {
+		photoIDs = append(photoIDs, form.Parameters.Photos...)
+	}
+
+	if len(photoIDs) == 0 {
+		return jobs.StatusDone, "no photos", nil
+	}
+
+	job.Data["photos"] = strings.Join(photoIDs, ",")
+
+	return jobs.StatusCleanupPending, fmt.Sprintf("the file storage cannot delete photos, %d left for cleanup", len(photoIDs)), nil
+}
+
+// withdrawJoinRequests records the pending requests, the matcher has no
+// call to withdraw them, so they are left for a manual cleanup.
+func (d *Saga) withdrawJoinRequests(ctx context.Context, job *jobs.Job) (jobs.Status, string, error) {
+	requests, err := d.matcher.GetRequestsByUserId(ctx, job.UserID)
+	if err != nil && !isNotFound(err) {
+		return "", "", err
+	}
+
+	if len(requests) == 0 {
+		return jobs.StatusDone, "no join requests", nil
+	}
+
+	ids := make([]string, 0, len(requests))
+	for _, request := range requests {
+		ids = append(ids, request.ID)
+	}
+	job.Data["join_requests"] = strings.Join(ids, ",")
+
+	return jobs.StatusCleanupPending, fmt.Sprintf("the matcher cannot withdraw join requests, %d left for cleanup", len(requests)), nil
+}
+
+// recordChats records the chats of the user. The chat service has no call
+// to delete chats or members, so they are left for a manual cleanup.
+func (d *Saga) recordChats(ctx context.Context, job *jobs.Job) (jobs.Status, string, error) {
+	chats, err := d.chats.GetChatList(ctx, job.UserID)
+	if err != nil && !isNotFound(err) {
+		return "", "", err
+	}
+
+	if len(chats) == 0 {
+		return jobs.StatusDone, "no chats", nil
+	}
+
+	ids := make([]string, 0, len(chats))
+	for _, chat := range chats {
+		ids = append(ids, chat.ID)
+	}
+	job.Data["chats"] = strings.Join(ids, ",")
+
+	return jobs.StatusCleanupPending, fmt.Sprintf("the chat service cannot delete chats, %d left for cleanup", len(chats)), nil
+}
+
+// leaveGroup deletes the group the user owns, the matcher cannot transfer
+// the ownership, and leaves any other group.
+func (d *Saga) leaveGroup(ctx context.Context, job *jobs.Job) (jobs.Status, string, error) {
+	group, err := d.matcher.GetGroupByUser(ctx, job.UserID)
+	if isNotFound(err) {
+		return jobs.StatusDone, "no group", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	if group.OwnerID == job.UserID {
+		if err := d.matcher.DeleteGroup(ctx, job.UserID); err != nil && !isNotFound(err) {
+			return "", "", err
+		}
+
+		return jobs.StatusDone, "owned group " + group.Id + " deleted", nil
+	}
+
+	if err := d.matcher.LeaveGroup(ctx, job.UserID); err != nil && !isNotFound(err) {
+		return "", "", err
+	}
+
+	return jobs.StatusDone, "left group " + group.Id, nil
+}
+
+func (d *Saga) deleteForm(ctx context.Context, job *jobs.Job) (jobs.Status, string, error) {
+	err := d.matcher.DeleteForm(ctx, job.UserID)
+	if isNotFound(err) {
+		return jobs.StatusDone, "no form", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	return jobs.StatusDone, "", nil
+}
+
+func (d *Saga) deleteUser(ctx context.Context, job *jobs.Job) (jobs.Status, string, error) {
+	err := d.users.DeleteUser(ctx, job.UserID)
+	if err != nil && !isNotFound(err) {
+		return "", "", err
+	}
+
+	return jobs.StatusDone, "", nil
+}
+
+// closeStreams closes the open streams of the user. The session that asked
+// for the deletion is logged out by Start, the auth service cannot revoke
+// the other sessions of a user.
+func (d *Saga) closeStreams(ctx context.Context, job *jobs.Job) (jobs.Status, string, error) {
+	closed := d.streams.CloseUser(job.UserID)
+
+	return jobs.StatusDone, "closed streams: " + strconv.Itoa(closed), nil
+}
+
+func isNotFound(err error) bool {
+	return status.Code(err) == codes.NotFound
+}
@@ -0,0 +1,360 @@
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"api-gateway/internal/jobs"
+)
+
+const (
+	archiveName  = "export.zip"
+	manifestName = "manifest.json"
+	photosDir    = "photos"
+)
+
+var unsafeNameRe = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// photoExtensions override mime.ExtensionsByType, which returns .jfif for
+// JPEG.
+var photoExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+	"image/heic": ".heic",
+}
+
+// workDir holds the sections until they are archived.
+func (e *Exporter) workDir(job *jobs.Job) string {
+	return filepath.Join(e.store.ArtifactDir(job.ID), "work")
+}
+
+func (e *Exporter) archivePath(job *jobs.Job) string {
+	return filepath.Join(e.store.ArtifactDir(job.ID), archiveName)
+}
+
+func writeJSON(dir, name string, v any) error {
+	const op = "export.writeJSON"
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// downloader fetches photo originals by their presigned URLs.
+type downloader struct {
+	client  *http.Client
+	maxSize int64
+}
+
+var errTooLarge = errors.New("photo is too large")
+
+func newDownloader(maxSize int64, timeout time.Duration) *downloader {
+	return &downloader{
+		client:  &http.Client{Timeout: timeout},
+		maxSize: maxSize,
+	}
+}
+
+// download writes the photo to dir/name and returns the file name with an
+// extension matching its content type.
+func (d *downloader) download(ctx context.Context, url, dir, name string) (string, error) {
+	const op = "export.downloader.download"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: unexpected status %s", op, resp.Status)
+	}
+	if resp.ContentLength > d.maxSize {
+		return "", errTooLarge
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, d.maxSize+1))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if int64(len(data)) > d.maxSize {
+		return "", errTooLarge
+	}
+
+	name += photoExtension(resp.Header.Get("Content-Type"))
+
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return name, nil
+}
+
+func photoExtension(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	if ext, ok := photoExtensions[mediaType]; ok {
+		return ext
+	}
+	if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+		return exts[0]
+	}
+
+	return ""
+}
+
+// exportPhotos downloads the originals of the avatar and the form photos.
+// Photos downloaded before a failure are kept, so a resumed step only
+// fetches the rest. The step has no deadline of its own, every photo is
+// bounded by PhotoTimeout.
+func (e *Exporter) exportPhotos(ctx context.Context, job *jobs.Job) (jobs.Status, string, error) {
+	var photoIDs []string
+	for _, ids := range []string{job.Data["avatar"], job.Data["form_photos"]} {
+		for _, id := range strings.Split(ids, ",") {
+			if id != "" {
+				photoIDs = append(photoIDs, id)
+			}
+		}
+	}
+
+	if len(photoIDs) == 0 {
+		return jobs.StatusDone, "no photos", nil
+	}
+
+	dir := filepath.Join(e.workDir(job), photosDir)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", "", err
+	}
+
+	var missing []string
+	for _, id := range photoIDs {
+		name := photoFileName(id)
+
+		if downloaded(dir, name) {
+			continue
+		}
+
+		err := e.exportPhoto(ctx, job.UserID, id, dir, name)
+		if isNotFound(err) || errors.Is(err, errTooLarge) {
+			missing = append(missing, id)
+			continue
+		}
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	job.Data["missing_photos"] = strings.Join(missing, ",")
+
+	if len(missing) > 0 {
+		return jobs.StatusDone, fmt.Sprintf("%d of %d photos could not be exported", len(missing), len(photoIDs)), nil
+	}
+
+	return jobs.StatusDone, "", nil
+}
+
+func (e *Exporter) exportPhoto(ctx context.Context, uid, id, dir, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, e.cfg.PhotoTimeout)
+	defer cancel()
+
+	url, err := e.storage.GetPhotoURL(ctx, uid, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.downloader.download(ctx, url, dir, name)
+
+	return err
+}
+
+// photoFileName returns the file name of a photo in the archive. IDs that
+// differ only in characters unsafe in file names would be cleaned to the
+// same name, a hash of the ID keeps the names of different photos apart.
+func photoFileName(id string) string {
+	sum := sha256.Sum256([]byte(id))
+
+	return unsafeNameRe.ReplaceAllString(id, "_") + "-" + hex.EncodeToString(sum[:4])
+}
+
+// downloaded reports whether the photo was saved, with or without an
+// extension, by an earlier run of the step.
+func downloaded(dir, name string) bool {
+	if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+		return true
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dir, name+".*"))
+
+	return len(matches) > 0
+}
+
+type manifest struct {
+	ExportID      string         `json:"export_id"`
+	UserID        string         `json:"user_id"`
+	CreatedAt     time.Time      `json:"created_at"`
+	GeneratedAt   time.Time      `json:"generated_at"`
+	Sections      []jobs.Step    `json:"sections"`
+	MissingPhotos []string       `json:"missing_photos,omitempty"`
+	Files         []manifestFile `json:"files"`
+}
+
+type manifestFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// archive packs the sections and a manifest into the ZIP archive. The
+// archive is renamed into place at the end, an existing one is complete.
+func (e *Exporter) archive(ctx context.Context, job *jobs.Job) (jobs.Status, string, error) {
+	const op = "export.Exporter.archive"
+
+	target := e.archivePath(job)
+	if _, err := os.Stat(target); err == nil {
+		return jobs.StatusDone, "", nil
+	}
+
+	workDir := e.workDir(job)
+
+	m := manifest{
+		ExportID:    job.ID,
+		UserID:      job.UserID,
+		CreatedAt:   job.CreatedAt,
+		GeneratedAt: time.Now().UTC(),
+	}
+	for _, step := range job.Steps {
+		if step.Name != stepArchive {
+			m.Sections = append(m.Sections, step)
+		}
+	}
+	if missing := job.Data["missing_photos"]; missing != "" {
+		m.MissingPhotos = strings.Split(missing, ",")
+	}
+
+	if err := os.MkdirAll(e.store.ArtifactDir(job.ID), 0o700); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	tmp, err := os.CreateTemp(e.store.ArtifactDir(job.ID), archiveName+".*.tmp")
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	zw := zip.NewWriter(tmp)
+
+	err = filepath.WalkDir(workDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		name, err := filepath.Rel(workDir, path)
+		if err != nil {
+			return err
+		}
+
+		file, err := addFile(zw, path, filepath.ToSlash(name))
+		if err != nil {
+			return err
+		}
+		m.Files = append(m.Files, file)
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	manifestData, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+	w, err := zw.Create(manifestName)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err := w.Write(manifestData); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := zw.Close(); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// the sections are in the archive now
+	if err := os.RemoveAll(workDir); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return jobs.StatusDone, fmt.Sprintf("%d files", len(m.Files)), nil
+}
+
+func addFile(zw *zip.Writer, path, name string) (manifestFile, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return manifestFile{}, err
+	}
+	defer src.Close()
+
+	dst, err := zw.Create(name)
+	if err != nil {
+		return manifestFile{}, err
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(dst, hash), src)
+	if err != nil {
+		return manifestFile{}, err
+	}
+
+	return manifestFile{
+		Name:   name,
+		Size:   size,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
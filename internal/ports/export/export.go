@@ -0,0 +1,289 @@
+// Package export assembles the personal data a user has in the backend
+// services into a ZIP archive of JSON files, as a background job. The
+// archive is kept for a limited time and then removed.
+package export
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/jobs"
+	"api-gateway/internal/models"
+	"api-gateway/internal/ports/handlers/matcher_handler"
+	"api-gateway/internal/ports/handlers/user_handler"
+	"api-gateway/internal/ports/photos"
+
+	"github.com/hesoyamTM/nbf-auth/pkg/logger"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const Kind = "data_export"
+
+// Steps of the export, in execution order. Every step but the last one
+// writes a section of the archive; photos needs the IDs found by profile
+// and form.
+const (
+	stepProfile       = "profile"
+	stepForm          = "form"
+	stepGroup         = "group"
+	stepChats         = "chats"
+	stepMessages      = "messages"
+	stepNotifications = "notifications"
+	stepPhotos        = "photos"
+	stepArchive       = "archive"
+)
+
+type UserClient interface {
+	GetUser(ctx context.Context, uid string) (*user_handler.User, error)
+}
+
+type MatcherClient interface {
+	GetFormByUser(ctx context.Context, uid string) (*matcher_handler.Form, error)
+	GetGroupByUser(ctx context.Context, uid string) (*matcher_handler.Group, error)
+	GetRequests(ctx context.Context, groupID string) ([]*matcher_handler.GroupRequest, error)
+	GetRequestsByUserId(ctx context.Context, uid string) ([]*matcher_handler.GroupRequest, error)
+}
+
+type ChatClient interface {
+	GetChatList(ctx context.Context, userID string) ([]models.Chat, error)
+}
+
+type NotificationClient interface {
+	GetNotificationList(ctx context.Context, userID string) ([]models.Notification, error)
+}
+
+type Exporter struct {
+	store         *jobs.Store
+	runner        *jobs.Runner
+	users         UserClient
+	matcher       MatcherClient
+	chats         ChatClient
+	notifications NotificationClient
+	storage       photos.URLGetter
+	downloader    *downloader
+	cfg           config.Jobs
+}
+
+func NewExporter(
+	store *jobs.Store,
+	users UserClient,
+	matcher MatcherClient,
+	chats ChatClient,
+	notifications NotificationClient,
+	storage photos.URLGetter,
+	cfg config.Jobs,
+) *Exporter {
+	e := &Exporter{
+		store:         store,
+		runner:        jobs.NewRunner(store, Kind, cfg.StepTimeout),
+		users:         users,
+		matcher:       matcher,
+		chats:         chats,
+		notifications: notifications,
+		storage:       storage,
+		downloader:    newDownloader(cfg.MaxPhotoSize, cfg.PhotoTimeout),
+		cfg:           cfg,
+	}
+
+	e.runner.Step(stepProfile, e.exportProfile)
+	e.runner.Step(stepForm, e.exportForm)
+	e.runner.Step(stepGroup, e.exportGroup)
+	e.runner.Step(stepChats, e.exportChats)
+	e.runner.Step(stepMessages, e.exportMessages)
+	e.runner.Step(stepNotifications, e.exportNotifications)
+	// every photo has its own deadline, see exportPhoto
+	e.runner.StepWithTimeout(stepPhotos, 0, e.exportPhotos)
+	e.runner.Step(stepArchive, e.archive)
+
+	return e
+}
+
+// Start starts an export of the user's data. An export that is still
+// running, or failed and can be resumed, is returned instead of starting
+// another one.
+func (e *Exporter) Start(ctx context.Context, uid string) (*jobs.Job, error) {
+	return e.runner.Start(ctx, uid, func(job *jobs.Job) bool {
+		return job.Unfinished()
+	})
+}
+
+// Get returns the export of the user, exports of other users are not found.
+func (e *Exporter) Get(uid, id string) (*jobs.Job, error) {
+	job, err := e.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Kind != Kind || job.UserID != uid {
+		return nil, jobs.ErrNotFound
+	}
+
+	return job, nil
+}
+
+// Open opens the archive of a finished export. The caller closes the file.
+func (e *Exporter) Open(uid, id string) (*os.File, *jobs.Job, error) {
+	const op = "export.Exporter.Open"
+
+	job, err := e.Get(uid, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if job.Status != jobs.StatusDone {
+		return nil, nil, jobs.ErrNotFinished
+	}
+	if time.Now().After(e.ExpiresAt(job)) {
+		return nil, nil, jobs.ErrExpired
+	}
+
+	f, err := os.Open(e.archivePath(job))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return f, job, nil
+}
+
+// ExpiresAt is when the archive of a finished export is removed.
+func (e *Exporter) ExpiresAt(job *jobs.Job) time.Time {
+	return job.UpdatedAt.Add(e.cfg.ExportTTL)
+}
+
+// Resume restarts the exports interrupted by a restart of the gateway.
+func (e *Exporter) Resume(ctx context.Context) {
+	e.runner.Resume(ctx)
+}
+
+// Watch removes expired exports every interval until ctx is done.
+func (e *Exporter) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.removeExpired(ctx)
+		}
+	}
+}
+
+func (e *Exporter) removeExpired(ctx context.Context) {
+	log, err := logger.LoggerFromCtx(ctx)
+	if err != nil {
+		return
+	}
+
+	expired, err := e.store.UpdatedBefore(Kind, time.Now().Add(-e.cfg.ExportTTL))
+	if err != nil {
+		log.Error("Failed to list expired exports", zap.Error(err))
+		return
+	}
+
+	for _, job := range expired {
+		// pending jobs are about to be resumed
+		if job.Status != jobs.StatusDone && job.Status != jobs.StatusFailed {
+			continue
+		}
+
+		if err := e.store.Delete(job.ID); err != nil {
+			log.Error("Failed to remove expired export", zap.String("job_id", job.ID), zap.Error(err))
+			continue
+		}
+
+		log.Info("Expired export removed", zap.String("job_id", job.ID))
+	}
+}
+
+func (e *Exporter) exportProfile(ctx context.Context, job *jobs.Job) (jobs.Status, string, error) {
+	user, err := e.users.GetUser(ctx, job.UserID)
+	if err != nil {
+		return "", "", err
+	}
+
+	job.Data["avatar"] = user.Avatar
+
+	return jobs.StatusDone, "", writeJSON(e.workDir(job), "profile.json", user)
+}
+
+func (e *Exporter) exportForm(ctx context.Context, job *jobs.Job) (jobs.Status, string, error) {
+	form, err := e.matcher.GetFormByUser(ctx, job.UserID)
+	if isNotFound(err) {
+		return jobs.StatusDone, "no form", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	job.Data["form_photos"] = strings.Join(form.Parameters.Photos, ",")
+
+	return jobs.StatusDone, "", writeJSON(e.workDir(job), "form.json", form)
+}
+
+// groupExport is the group of the user with the requests to join it, if the
+// user owns it, and the requests the user sent to other groups.
+type groupExport struct {
+	Group         *matcher_handler.Group          `json:"group,omitempty"`
+	GroupRequests []*matcher_handler.GroupRequest `json:"group_requests,omitempty"`
+	JoinRequests  []*matcher_handler.GroupRequest `json:"join_requests"`
+}
+
+func (e *Exporter) exportGroup(ctx context.Context, job *jobs.Job) (jobs.Status, string, error) {
+	var export groupExport
+
+	group, err := e.matcher.GetGroupByUser(ctx, job.UserID)
+	if err != nil && !isNotFound(err) {
+		return "", "", err
+	}
+	if group != nil {
+		export.Group = group
+
+		if group.OwnerID == job.UserID {
+			export.GroupRequests, err = e.matcher.GetRequests(ctx, group.Id)
+			if err != nil && !isNotFound(err) {
+				return "", "", err
+			}
+		}
+	}
+
+	export.JoinRequests, err = e.matcher.GetRequestsByUserId(ctx, job.UserID)
+	if err != nil && !isNotFound(err) {
+		return "", "", err
+	}
+
+	return jobs.StatusDone, "", writeJSON(e.workDir(job), "group.json", export)
+}
+
+func (e *Exporter) exportChats(ctx context.Context, job *jobs.Job) (jobs.Status, string, error) {
+	chats, err := e.chats.GetChatList(ctx, job.UserID)
+	if err != nil && !isNotFound(err) {
+		return "", "", err
+	}
+
+	return jobs.StatusDone, "", writeJSON(e.workDir(job), "chats.json", chats)
+}
+
+// exportMessages is a placeholder section, the chat service only streams
+// new messages and has no call for the history.
+func (e *Exporter) exportMessages(context.Context, *jobs.Job) (jobs.Status, string, error) {
+	return jobs.StatusSkipped, "the chat service cannot list past messages", nil
+}
+
+func (e *Exporter) exportNotifications(ctx context.Context, job *jobs.Job) (jobs.Status, string, error) {
+	notifications, err := e.notifications.GetNotificationList(ctx, job.UserID)
+	if err != nil && !isNotFound(err) {
+		return "", "", err
+	}
+
+	return jobs.StatusDone, "", writeJSON(e.workDir(job), "notifications.json", notifications)
+}
+
+func isNotFound(err error) bool {
+	return status.Code(err) == codes.NotFound
+}
@@ -4,19 +4,61 @@ import "time"
 
 //requests
 
+// @Description Phone number registration request
 type RegisterRequest struct {
-	Name        string
-	Surname     string
-	PhoneNumber string
+	Name        string `json:"name"`
+	Surname     string `json:"surname"`
+	PhoneNumber string `json:"phone_number"`
 }
 
+// @Description Phone number login request
 type LoginRequest struct {
-	PhoneNumber string
+	PhoneNumber string `json:"phone_number"`
+}
+
+// @Description Verification of the code sent to the phone number
+type VerifyPhoneRequest struct {
+	Token string `json:"token"`
+	Code  string `json:"code"`
 }
 
 // @Description User logout request
 type LogoutRequest struct {
-	RefreshToken string
+	RefreshToken string `json:"refresh_token"`
+}
+
+// @Description Token refresh request of clients without cookies
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+//responses
+
+// @Description Token to pass to /auth/phone/verify together with the code
+type VerificationResponse struct {
+	Token string `json:"token"`
+}
+
+// @Description Tokens for clients that do not use cookies
+type TokenResponse struct {
+	TokenType       string    `json:"token_type"`
+	AccessToken     string    `json:"access_token"`
+	RefreshToken    string    `json:"refresh_token"`
+	AccessExpireAt  time.Time `json:"access_expire_at"`
+	RefreshExpireAt time.Time `json:"refresh_expire_at"`
+}
+
+// @Description OAuth provider to render a login button for
+type ProviderResponse struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	LoginURL    string `json:"login_url"`
+	CallbackURL string `json:"callback_url"`
+}
+
+// @Description Enabled OAuth providers
+type ProvidersResponse struct {
+	Providers []ProviderResponse `json:"providers"`
 }
 
 //dto for interface
@@ -30,13 +72,6 @@ type Tokens struct {
 }
 
 /*
-type VerifyPhoneResponce struct {
-	User_ID           string
-	AccessToken       string
-	RefreshToken      string
-	Access_expire_at  time.Time
-	Refresh_expire_at time.Time
-}
 type RefreshTokenResponce struct {
 	User_ID           string
 	AccessToken       string
@@ -4,8 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
-	"time"
+	"regexp"
+	"strings"
 
+	"api-gateway/internal/config"
+	"api-gateway/internal/ports/apierror"
+	"api-gateway/internal/ports/cookies"
+
+	"github.com/go-chi/chi"
 	"github.com/go-chi/render"
 	"github.com/hesoyamTM/nbf-auth/pkg/logger"
 	"go.uber.org/zap"
@@ -15,268 +21,423 @@ type AuthClient interface {
 	Register(ctx context.Context, phone_number, name, surname string) (string, error)
 	Login(ctx context.Context, phone_number string) (string, error)
 	Logout(ctx context.Context, refresh_token string) error
-	//VerifyPhoneNumber(ctx context.Context, token, code string) (*Tokens, error)
+	VerifyPhoneNumber(ctx context.Context, token, code string) (*Tokens, error)
 	RefreshToken(ctx context.Context, token string) (*Tokens, error)
-	YandexLoginURL(ctx context.Context) (string, error)
-	YandexAuthorize(ctx context.Context, state, code string) (*Tokens, error)
-	GoogleLoginURL(ctx context.Context) (string, error)
-	GoogleAuthorize(ctx context.Context, state, code string) (*Tokens, error)
 }
 
 type AuthHandler struct {
 	authClient AuthClient
-	domain     string
+	cookies    *cookies.Issuer
+	phones     *phoneLimiter
+	providers  *Providers
+	oauth      *oauthRedirects
 }
 
-func NewAuthHandler(authClient AuthClient, domain string) *AuthHandler {
+func NewAuthHandler(authClient AuthClient, providers *Providers, cookieIssuer *cookies.Issuer, cfg config.Auth) *AuthHandler {
 	return &AuthHandler{
 		authClient: authClient,
-		domain:     domain,
+		cookies:    cookieIssuer,
+		phones:     newPhoneLimiter(cfg.Phone),
+		providers:  providers,
+		oauth: &oauthRedirects{
+			cfg:     cfg.OAuth,
+			cookies: cookieIssuer,
+		},
 	}
 }
 
-// @Summary Logout
-// @Description Logout user
+var phoneRe = regexp.MustCompile(`^\+?[0-9]{10,15}$`)
+
+// normalizePhone strips formatting from a phone number and returns it in
+// E.164 form, so that the same number written differently is limited and
+// sent to the auth service as one number.
+func normalizePhone(phone string) (string, bool) {
+	phone = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '(', ')':
+			return -1
+		}
+		return r
+	}, phone)
+
+	if !phoneRe.MatchString(phone) {
+		return "", false
+	}
+
+	return "+" + strings.TrimPrefix(phone, "+"), true
+}
+
+// @Summary Register by phone number
+// @Description Отправляет код подтверждения на номер телефона нового пользователя
 // @Tags auth
 // @Accept json
-// @Header 200 {string} Set-Cookie "access_token=<access_token>; HttpOnly;"
-// Header 200 {string} Set-Cookie "refresh_token=<refresh_token>; HttpOnly;"
-// @Failure 400
-// @Failure 401
-// @Failure 500
-// @Router /auth/logout [delete]
-func (c *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+// @Produce json
+// @Param request body RegisterRequest true "User data"
+// @Success 200 {object} VerificationResponse
+// @Failure 400 {object} apierror.Error
+// @Failure 409 {object} apierror.Error
+// @Failure 429 {object} apierror.Error
+// @Failure 500 {object} apierror.Error
+// @Router /auth/phone/register [post]
+func (c *AuthHandler) PhoneRegister(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 
 		return
 	}
 
-	refreshCookie, err := r.Cookie("refresh_token")
-	if err != nil {
-		log.Error("Failed to fetch refresh cookie", zap.Error(err))
+	var req RegisterRequest
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		log.Error("Failed to decode JSON", zap.Error(err))
 
-		http.Error(w, "Failed to fetch cookie", http.StatusUnauthorized)
+		apierror.BadRequest(w, r, "Invalid JSON")
 
 		return
 	}
-	if refreshCookie.Value == "" {
-		log.Error("refresh token value is empty", zap.Error(err))
 
-		http.Error(w, "Empty value of refresh token", http.StatusBadRequest)
+	var fields []apierror.FieldError
+	if strings.TrimSpace(req.Name) == "" {
+		fields = append(fields, apierror.FieldError{Field: "name", Message: "is required"})
+	}
+	if strings.TrimSpace(req.Surname) == "" {
+		fields = append(fields, apierror.FieldError{Field: "surname", Message: "is required"})
+	}
+	phone, ok := normalizePhone(req.PhoneNumber)
+	if !ok {
+		fields = append(fields, apierror.FieldError{Field: "phone_number", Message: "must contain 10 to 15 digits"})
+	}
+	if len(fields) > 0 {
+		apierror.BadRequest(w, r, "Invalid registration data", fields...)
 
 		return
 	}
 
-	ctx := r.Context()
+	c.sendCode(w, r, phone, func(ctx context.Context) (string, error) {
+		return c.authClient.Register(ctx, phone, req.Name, req.Surname)
+	})
+}
 
-	if err := c.authClient.Logout(ctx, refreshCookie.Value); err != nil {
-		log.Error("Logout failed", zap.Error(err))
+// @Summary Login by phone number
+// @Description Отправляет код подтверждения на номер телефона
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body LoginRequest true "Phone number"
+// @Success 200 {object} VerificationResponse
+// @Failure 400 {object} apierror.Error
+// @Failure 404 {object} apierror.Error
+// @Failure 429 {object} apierror.Error
+// @Failure 500 {object} apierror.Error
+// @Router /auth/phone/login [post]
+func (c *AuthHandler) PhoneLogin(w http.ResponseWriter, r *http.Request) {
+	log, err := logger.LoggerFromCtx(r.Context())
+	if err != nil {
+		apierror.Internal(w, r, "Internal error")
 
-		http.Error(w, "Logout failed", http.StatusInternalServerError)
+		return
+	}
+
+	var req LoginRequest
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		log.Error("Failed to decode JSON", zap.Error(err))
+
+		apierror.BadRequest(w, r, "Invalid JSON")
 
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "access_token",
-		Value:    "",
-		HttpOnly: true,
-		Domain:   c.domain,
-		Path:     "/",
-		Expires:  time.Unix(0, 0),
-	})
-	http.SetCookie(w, &http.Cookie{
-		Name:     "refresh_token",
-		Value:    "",
-		HttpOnly: true,
-		Domain:   c.domain,
-		Path:     "/",
-		Expires:  time.Unix(0, 0),
+	phone, ok := normalizePhone(req.PhoneNumber)
+	if !ok {
+		apierror.BadRequest(w, r, "Invalid phone number",
+			apierror.FieldError{Field: "phone_number", Message: "must contain 10 to 15 digits"})
+
+		return
+	}
+
+	c.sendCode(w, r, phone, func(ctx context.Context) (string, error) {
+		return c.authClient.Login(ctx, phone)
 	})
+}
+
+// sendCode asks the auth service to send a code unless one was sent to the
+// phone recently, and replies with the verification token.
+func (c *AuthHandler) sendCode(w http.ResponseWriter, r *http.Request, phone string, send func(ctx context.Context) (string, error)) {
+	log, err := logger.LoggerFromCtx(r.Context())
+	if err != nil {
+		apierror.Internal(w, r, "Internal error")
+
+		return
+	}
+
+	wait, cancel := c.phones.reserveSend(phone)
+	if wait > 0 {
+		apiErr := apierror.New(http.StatusTooManyRequests, apierror.CodeTooManyRequests, "Code was sent recently, try again later")
+		apiErr.RetryAfter = wait
+		apierror.Write(w, r, apiErr)
+
+		return
+	}
+
+	token, err := send(r.Context())
+	if err != nil {
+		cancel()
+		log.Error("Failed to send verification code", zap.Error(err))
+
+		apierror.GRPC(w, r, err, "Failed to send verification code")
+
+		return
+	}
+
+	c.phones.bind(token, phone)
 
 	render.Status(r, http.StatusOK)
+	render.JSON(w, r, VerificationResponse{
+		Token: token,
+	})
 }
 
-// @Summary Refresh tokens
-// @Description Обновляет access и refresh токены
+// @Summary Verify phone number
+// @Description Проверяет код подтверждения и выдает токены
 // @Tags auth
 // @Accept json
+// @Param request body VerifyPhoneRequest true "Verification token and code"
+// @Param response_mode query string false "json to receive the tokens in the body instead of cookies"
+// @Success 200 {object} TokenResponse
 // @Header 200 {string} Set-Cookie "access_token=<access_token>; HttpOnly;"
 // Header 200 {string} Set-Cookie "refresh_token=<refresh_token>; HttpOnly;"
-// @Failure 400
-// @Failure 401
-// @Failure 500
-// @Router /auth/refresh [head]
-func (c *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+// @Failure 400 {object} apierror.Error
+// @Failure 401 {object} apierror.Error
+// @Failure 422 {object} apierror.Error
+// @Failure 429 {object} apierror.Error
+// @Failure 500 {object} apierror.Error
+// @Router /auth/phone/verify [post]
+func (c *AuthHandler) PhoneVerify(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 
 		return
 	}
 
-	refreshCookie, err := r.Cookie("refresh_token")
-	if err != nil {
-		log.Error("Failed to fetch refresh cookie", zap.Error(err))
+	var req VerifyPhoneRequest
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		log.Error("Failed to decode JSON", zap.Error(err))
+
+		apierror.BadRequest(w, r, "Invalid JSON")
+
+		return
+	}
 
-		http.Error(w, "Failed to fetch cookie", http.StatusUnauthorized)
+	if req.Token == "" || req.Code == "" {
+		apierror.BadRequest(w, r, "Token or code is empty")
 
 		return
 	}
-	if refreshCookie.Value == "" {
-		log.Error("Refresh token value is empty", zap.Error(err))
 
-		http.Error(w, "Empty value of refresh token", http.StatusBadRequest)
+	known, allowed := c.phones.attempt(req.Token)
+	if !known {
+		apierror.Unprocessable(w, r, "Unknown or expired token, request a new code",
+			apierror.FieldError{Field: "token", Message: "is unknown or expired"})
+
+		return
+	}
+	if !allowed {
+		apierror.Write(w, r, apierror.New(http.StatusTooManyRequests, apierror.CodeTooManyRequests, "Too many attempts, try again later"))
 
 		return
 	}
 
 	ctx := r.Context()
 
-	resp, err := c.authClient.RefreshToken(ctx, refreshCookie.Value)
+	resp, err := c.authClient.VerifyPhoneNumber(ctx, req.Token, req.Code)
 	if err != nil {
-		log.Error("Failed to fetch refresh cookie", zap.Error(err))
+		log.Error("Failed to verify phone number", zap.Error(err))
 
-		http.Error(w, "token refresh failed", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "verification failed")
 
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "access_token",
-		Value:    resp.AccessToken,
-		HttpOnly: true,
-		Domain:   c.domain,
-		Path:     "/",
-		Expires:  resp.Access_expire_at,
-	})
-	http.SetCookie(w, &http.Cookie{
-		Name:     "refresh_token",
-		Value:    resp.RefreshToken,
-		HttpOnly: true,
-		Domain:   c.domain,
-		Path:     "/",
-		Expires:  resp.Refresh_expire_at,
-	})
+	c.phones.verified(req.Token)
 
-	render.Status(r, http.StatusOK)
+	c.issueTokens(w, r, resp, wantsTokenResponse(r))
 }
 
-// @Summary Google Yandex URL
-// @Description Получить ссылку на авторизацию Yandex OAuth
+// @Summary Logout
+// @Description Logout user
 // @Tags auth
 // @Accept json
-// @Produce json
-// @Success 200 {object} map[string]string
+// @Param request body LogoutRequest false "Refresh token of clients without cookies"
+// @Header 200 {string} Set-Cookie "access_token=<access_token>; HttpOnly;"
+// Header 200 {string} Set-Cookie "refresh_token=<refresh_token>; HttpOnly;"
+// @Failure 400
+// @Failure 401
 // @Failure 500
-// @Router /auth/yandex/login [get]
-func (c *AuthHandler) YandexLoginURL(w http.ResponseWriter, r *http.Request) {
+// @Router /auth/logout [delete]
+func (c *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 
 		return
 	}
 
-	ctx := r.Context()
-	url, err := c.authClient.YandexLoginURL(ctx)
+	refreshToken, fromBody, err := readRefreshToken(r)
 	if err != nil {
-		log.Error("failed to get yandex url", zap.Error(err))
+		log.Error("Failed to read refresh token", zap.Error(err))
 
-		http.Error(w, "failed to get yandex url", http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeUnauthorized, "Failed to fetch refresh token"))
 
 		return
 	}
+	if refreshToken == "" {
+		log.Error("Refresh token value is empty")
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"Url": url,
-	})
+		apierror.BadRequest(w, r, "Empty value of refresh token")
+
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := c.authClient.Logout(ctx, refreshToken); err != nil {
+		log.Error("Logout failed", zap.Error(err))
+
+		apierror.GRPC(w, r, err, "Logout failed")
+
+		return
+	}
+
+	if !fromBody {
+		c.cookies.ClearTokens(w)
+	}
 
 	render.Status(r, http.StatusOK)
 }
 
-// @Summary Yandex authorize
-// @Description Authorize user using Yandex OAuth callback
+// @Summary Refresh tokens
+// @Description Обновляет access и refresh токены
 // @Tags auth
 // @Accept json
-// @Param state query string true "OAuth state parameter"
-// @Param code query string true "OAuth authorization code"
+// @Param request body RefreshRequest false "Refresh token of clients without cookies, tokens are returned in the body"
+// @Param response_mode query string false "json to receive the tokens in the body instead of cookies"
+// @Success 200 {object} TokenResponse
 // @Header 200 {string} Set-Cookie "access_token=<access_token>; HttpOnly;"
 // Header 200 {string} Set-Cookie "refresh_token=<refresh_token>; HttpOnly;"
 // @Failure 400
 // @Failure 401
 // @Failure 500
-// @Router /auth/yandex/callback [get]
-func (c *AuthHandler) YandexAuthorize(w http.ResponseWriter, r *http.Request) {
+// @Router /auth/refresh [head]
+// @Router /auth/refresh [post]
+func (c *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 
 		return
 	}
 
-	state := r.URL.Query().Get("state")
-	code := r.URL.Query().Get("code")
+	refreshToken, fromBody, err := readRefreshToken(r)
+	if err != nil {
+		log.Error("Failed to read refresh token", zap.Error(err))
+
+		apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeUnauthorized, "Failed to fetch refresh token"))
+
+		return
+	}
+	if refreshToken == "" {
+		log.Error("Refresh token value is empty")
+
+		apierror.BadRequest(w, r, "Empty value of refresh token")
+
+		return
+	}
 
 	ctx := r.Context()
-	resp, err := c.authClient.YandexAuthorize(ctx, state, code)
+
+	resp, err := c.authClient.RefreshToken(ctx, refreshToken)
 	if err != nil {
-		log.Error("Failed to yandex authorize user", zap.Error(err))
+		log.Error("Failed to refresh token", zap.Error(err))
 
-		http.Error(w, "authorization failed", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "token refresh failed")
 
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "access_token",
-		Value:    resp.AccessToken,
-		HttpOnly: true,
-		Domain:   c.domain,
-		Path:     "/",
-		Expires:  resp.Access_expire_at,
-	})
-	http.SetCookie(w, &http.Cookie{
-		Name:     "refresh_token",
-		Value:    resp.RefreshToken,
-		HttpOnly: true,
-		Domain:   c.domain,
-		Path:     "/",
-		Expires:  resp.Refresh_expire_at,
-	})
+	c.issueTokens(w, r, resp, fromBody || wantsTokenResponse(r))
+}
+
+// @Summary OAuth providers
+// @Description Список доступных OAuth провайдеров для кнопок входа
+// @Tags auth
+// @Produce json
+// @Success 200 {object} ProvidersResponse
+// @Router /auth/providers [get]
+func (c *AuthHandler) ListProviders(w http.ResponseWriter, r *http.Request) {
+	resp := ProvidersResponse{
+		Providers: make([]ProviderResponse, 0, len(c.providers.list)),
+	}
+	for _, p := range c.providers.list {
+		resp.Providers = append(resp.Providers, ProviderResponse{
+			Name:        p.name,
+			Title:       p.title,
+			LoginURL:    "/api/v1/auth/" + p.name + "/login",
+			CallbackURL: "/api/v1/auth/" + p.name + "/callback",
+		})
+	}
 
 	render.Status(r, http.StatusOK)
+	render.JSON(w, r, resp)
 }
 
-// @Summary Google login URL
-// @Description Получить ссылку на авторизацию Google OAuth
+// @Summary OAuth login URL
+// @Description Получить ссылку на авторизацию через OAuth провайдера
 // @Tags auth
 // @Accept json
 // @Produce json
+// @Param provider path string true "Provider name from /auth/providers"
+// @Param return_to query string false "Frontend URL or path to return to after login"
 // @Success 200 {object} map[string]string
+// @Failure 400 {object} apierror.Error
+// @Failure 404 {object} apierror.Error
 // @Failure 500
-// @Router /auth/google/login [get]
-func (c *AuthHandler) GoogleLoginURL(w http.ResponseWriter, r *http.Request) {
+// @Router /auth/{provider}/login [get]
+func (c *AuthHandler) OAuthLoginURL(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
+
+		return
+	}
+
+	provider, ok := c.providers.get(chi.URLParam(r, "provider"))
+	if !ok {
+		apierror.Write(w, r, apierror.New(http.StatusNotFound, apierror.CodeNotFound, "Unknown OAuth provider"))
+
+		return
+	}
+
+	returnTo, err := c.oauth.resolve(r.URL.Query().Get("return_to"))
+	if err != nil {
+		apierror.BadRequest(w, r, "Invalid return_to",
+			apierror.FieldError{Field: "return_to", Message: err.Error()})
 
 		return
 	}
 
 	ctx := r.Context()
-	url, err := c.authClient.GoogleLoginURL(ctx)
+	url, err := provider.LoginURL(ctx)
 	if err != nil {
-		log.Error("failed to get google url", zap.Error(err))
+		log.Error("failed to get login url", zap.String("provider", provider.name), zap.Error(err))
 
-		http.Error(w, "failed to get google url", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "failed to get "+provider.name+" url")
 
 		return
 	}
 
+	c.oauth.bind(w, url, returnTo)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
 		"Url": url,
@@ -285,56 +446,136 @@ func (c *AuthHandler) GoogleLoginURL(w http.ResponseWriter, r *http.Request) {
 	render.Status(r, http.StatusOK)
 }
 
-// @Summary Google authorize
-// @Description Authorize user using Google OAuth callback
+// @Summary OAuth authorize
+// @Description Authorize user using the OAuth callback of the provider
 // @Tags auth
 // @Accept json
+// @Param provider path string true "Provider name from /auth/providers"
 // @Param state query string true "OAuth state parameter"
 // @Param code query string true "OAuth authorization code"
+// @Param response_mode query string false "json to receive the tokens in the body instead of cookies"
+// @Success 200 {object} TokenResponse
 // @Header 200 {string} Set-Cookie "access_token=<access_token>; HttpOnly;"
 // Header 200 {string} Set-Cookie "refresh_token=<refresh_token>; HttpOnly;"
+// @Header 302 {string} Location "return_to bound to the state, or the error page with a reason"
 // @Failure 400
 // @Failure 401
+// @Failure 404
 // @Failure 500
-// @Router /auth/google/callback [get]
-func (c *AuthHandler) GoogleAuthorize(w http.ResponseWriter, r *http.Request) {
+// @Router /auth/{provider}/callback [get]
+func (c *AuthHandler) OAuthCallback(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
+
+		return
+	}
+
+	provider, ok := c.providers.get(chi.URLParam(r, "provider"))
+	if !ok {
+		apierror.Write(w, r, apierror.New(http.StatusNotFound, apierror.CodeNotFound, "Unknown OAuth provider"))
+
+		return
+	}
+
+	query := r.URL.Query()
+
+	// native clients get the tokens and errors as JSON instead of redirects
+	fail := c.oauth.fail
+	if wantsTokenResponse(r) {
+		fail = func(w http.ResponseWriter, r *http.Request, _ string, apiErr *apierror.Error) {
+			apierror.Write(w, r, apiErr)
+		}
+	}
+
+	if providerErr := query.Get("error"); providerErr != "" {
+		log.Warn("OAuth provider returned an error", zap.String("provider", provider.name), zap.String("error", providerErr))
+
+		fail(w, r, ReasonProviderError, apierror.New(http.StatusUnauthorized, apierror.CodeUnauthorized, "authorization failed"))
 
 		return
 	}
 
-	state := r.URL.Query().Get("state")
-	code := r.URL.Query().Get("code")
+	state := query.Get("state")
+	code := query.Get("code")
+	if state == "" || code == "" {
+		fail(w, r, ReasonInvalidRequest, apierror.New(http.StatusBadRequest, apierror.CodeBadRequest, "State or code is empty"))
+
+		return
+	}
 
 	ctx := r.Context()
 
-	resp, err := c.authClient.GoogleAuthorize(ctx, state, code)
+	resp, err := provider.Authorize(ctx, state, code)
 	if err != nil {
-		log.Error("Failed to google authorize user", zap.Error(err))
+		log.Error("Failed to authorize user", zap.String("provider", provider.name), zap.Error(err))
 
-		http.Error(w, "authorization failed", http.StatusInternalServerError)
+		apiErr := apierror.FromGRPC(err, "authorization failed")
+		fail(w, r, apiErr.Code, apiErr)
 
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "access_token",
-		Value:    resp.AccessToken,
-		HttpOnly: true,
-		Domain:   c.domain,
-		Path:     "/",
-		Expires:  resp.Access_expire_at,
-	})
-	http.SetCookie(w, &http.Cookie{
-		Name:     "refresh_token",
-		Value:    resp.RefreshToken,
-		HttpOnly: true,
-		Domain:   c.domain,
-		Path:     "/",
-		Expires:  resp.Refresh_expire_at,
-	})
+	if wantsTokenResponse(r) {
+		c.issueTokens(w, r, resp, true)
+
+		return
+	}
+
+	c.cookies.SetTokens(w, resp.AccessToken, resp.Access_expire_at, resp.RefreshToken, resp.Refresh_expire_at)
+
+	target := c.oauth.returnTo(w, r)
+	if target == "" {
+		render.Status(r, http.StatusOK)
+
+		return
+	}
+
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// wantsTokenResponse reports whether the client asked for the tokens in the
+// response body instead of cookies.
+func wantsTokenResponse(r *http.Request) bool {
+	return r.URL.Query().Get("response_mode") == "json"
+}
+
+// issueTokens sends the tokens as JSON or sets them as cookies.
+func (c *AuthHandler) issueTokens(w http.ResponseWriter, r *http.Request, tokens *Tokens, asJSON bool) {
+	if !asJSON {
+		c.cookies.SetTokens(w, tokens.AccessToken, tokens.Access_expire_at, tokens.RefreshToken, tokens.Refresh_expire_at)
+
+		render.Status(r, http.StatusOK)
+
+		return
+	}
 
 	render.Status(r, http.StatusOK)
+	render.JSON(w, r, TokenResponse{
+		TokenType:       "Bearer",
+		AccessToken:     tokens.AccessToken,
+		RefreshToken:    tokens.RefreshToken,
+		AccessExpireAt:  tokens.Access_expire_at,
+		RefreshExpireAt: tokens.Refresh_expire_at,
+	})
+}
+
+// readRefreshToken takes the refresh token from the JSON body of native
+// clients or from the cookie of browsers.
+func readRefreshToken(r *http.Request) (string, bool, error) {
+	if r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0 {
+		var req RefreshRequest
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			return "", true, err
+		}
+
+		return req.RefreshToken, true, nil
+	}
+
+	cookie, err := r.Cookie(cookies.RefreshToken)
+	if err != nil {
+		return "", false, err
+	}
+
+	return cookie.Value, false, nil
 }
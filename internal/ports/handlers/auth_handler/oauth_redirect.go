@@ -0,0 +1,148 @@
+package auth_handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/ports/apierror"
+	"api-gateway/internal/ports/cookies"
+)
+
+// Reason codes sent to the error page.
+const (
+	ReasonProviderError  = "provider_error"
+	ReasonInvalidRequest = "invalid_request"
+)
+
+var errReturnTo = errors.New("return_to is not allowed")
+
+// oauthState is kept in a cookie between the login URL and the callback.
+type oauthState struct {
+	State    string `json:"state"`
+	ReturnTo string `json:"return_to"`
+}
+
+// oauthRedirects resolves where the browser is sent after a callback.
+type oauthRedirects struct {
+	cfg     config.OAuth
+	cookies *cookies.Issuer
+}
+
+// resolve validates return_to against the allow-list. Relative paths are
+// resolved against the default return URL.
+func (o *oauthRedirects) resolve(returnTo string) (string, error) {
+	if returnTo == "" {
+		return o.cfg.DefaultReturnURL, nil
+	}
+
+	// "//host" and "\host" are treated as absolute URLs by browsers
+	if strings.HasPrefix(returnTo, "//") || strings.Contains(returnTo, "\\") {
+		return "", errReturnTo
+	}
+
+	// a return URL never needs userinfo, it only hides the real host
+	target, err := url.Parse(returnTo)
+	if err != nil || target.User != nil {
+		return "", errReturnTo
+	}
+
+	if !target.IsAbs() {
+		if o.cfg.DefaultReturnURL == "" || !strings.HasPrefix(target.Path, "/") {
+			return "", errReturnTo
+		}
+
+		base, err := url.Parse(o.cfg.DefaultReturnURL)
+		if err != nil {
+			return "", errReturnTo
+		}
+
+		return base.ResolveReference(target).String(), nil
+	}
+
+	origin := target.Scheme + "://" + target.Host
+	if !slices.Contains(o.cfg.AllowedOrigins, origin) {
+		return "", errReturnTo
+	}
+
+	return target.String(), nil
+}
+
+// bind stores return_to together with the state of the provider login URL.
+func (o *oauthRedirects) bind(w http.ResponseWriter, loginURL, returnTo string) {
+	u, err := url.Parse(loginURL)
+	if err != nil {
+		return
+	}
+
+	state := u.Query().Get("state")
+	if state == "" || returnTo == "" {
+		return
+	}
+
+	value, err := json.Marshal(oauthState{
+		State:    state,
+		ReturnTo: returnTo,
+	})
+	if err != nil {
+		return
+	}
+
+	o.cookies.Set(w, cookies.OAuthState, base64.RawURLEncoding.EncodeToString(value), time.Now().Add(o.cfg.StateTTL))
+}
+
+// returnTo reads the bound return_to of the callback state and clears the
+// cookie. The default return URL is used when the state does not match.
+func (o *oauthRedirects) returnTo(w http.ResponseWriter, r *http.Request) string {
+	cookie, err := r.Cookie(cookies.OAuthState)
+	if err != nil {
+		return o.cfg.DefaultReturnURL
+	}
+
+	o.cookies.Clear(w, cookies.OAuthState)
+
+	value, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		return o.cfg.DefaultReturnURL
+	}
+
+	var bound oauthState
+	if err := json.Unmarshal(value, &bound); err != nil || bound.State != r.URL.Query().Get("state") {
+		return o.cfg.DefaultReturnURL
+	}
+
+	// the allow-list may have changed since the login
+	target, err := o.resolve(bound.ReturnTo)
+	if err != nil {
+		return o.cfg.DefaultReturnURL
+	}
+
+	return target
+}
+
+// fail redirects to the error page with a reason code, or renders the
+// error as JSON if no error page is configured.
+func (o *oauthRedirects) fail(w http.ResponseWriter, r *http.Request, reason string, apiErr *apierror.Error) {
+	if o.cfg.ErrorURL == "" {
+		apierror.Write(w, r, apiErr)
+		return
+	}
+
+	target, err := url.Parse(o.cfg.ErrorURL)
+	if err != nil {
+		apierror.Internal(w, r, "Internal error")
+		return
+	}
+
+	query := target.Query()
+	query.Set("reason", reason)
+	target.RawQuery = query.Encode()
+
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
@@ -0,0 +1,131 @@
+package auth_handler
+
+import (
+	"sync"
+	"time"
+
+	"api-gateway/internal/config"
+)
+
+// phoneState lives for CodeTTL after the first code was sent, so that
+// resending a code does not reset the attempts left.
+type phoneState struct {
+	startedAt time.Time
+	sentAt    time.Time
+	attempts  int
+}
+
+// phoneLimiter throttles sending of verification codes and limits the
+// number of attempts to enter a code, per phone number. Verification
+// tokens are mapped to the phone number they were issued for.
+type phoneLimiter struct {
+	cfg config.PhoneAuth
+
+	mu     sync.Mutex
+	phones map[string]*phoneState
+	tokens map[string]string
+}
+
+func newPhoneLimiter(cfg config.PhoneAuth) *phoneLimiter {
+	return &phoneLimiter{
+		cfg:    cfg,
+		phones: make(map[string]*phoneState),
+		tokens: make(map[string]string),
+	}
+}
+
+// reserveSend returns how long to wait if a code was sent to the phone
+// recently. Otherwise the send is recorded, cancel undoes it.
+func (l *phoneLimiter) reserveSend(phone string) (wait time.Duration, cancel func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune()
+
+	now := time.Now()
+	state, existed := l.phones[phone]
+	if existed {
+		if wait := l.cfg.ResendInterval - now.Sub(state.sentAt); wait > 0 {
+			return wait, nil
+		}
+	} else {
+		state = &phoneState{startedAt: now}
+		l.phones[phone] = state
+	}
+
+	prevSentAt := state.sentAt
+	state.sentAt = now
+
+	return 0, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if l.phones[phone] != state {
+			return
+		}
+		if existed {
+			state.sentAt = prevSentAt
+		} else {
+			delete(l.phones, phone)
+		}
+	}
+}
+
+// bind remembers the phone number a verification token was issued for.
+func (l *phoneLimiter) bind(token, phone string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens[token] = phone
+}
+
+// attempt records an attempt to enter the code and reports whether the
+// token is known and the attempt is still allowed. Tokens this gateway did
+// not issue, or whose code has expired, are not recorded, so that made-up
+// tokens cannot grow the limiter.
+func (l *phoneLimiter) attempt(token string) (known, allowed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune()
+
+	phone, ok := l.tokens[token]
+	if !ok {
+		return false, false
+	}
+
+	state := l.phones[phone]
+	state.attempts++
+
+	return true, state.attempts <= l.cfg.MaxAttempts
+}
+
+// verified forgets the token, the next code can be requested right away.
+func (l *phoneLimiter) verified(token string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	phone, ok := l.tokens[token]
+	if !ok {
+		return
+	}
+
+	delete(l.tokens, token)
+	delete(l.phones, phone)
+}
+
+func (l *phoneLimiter) prune() {
+	now := time.Now()
+
+	for phone, state := range l.phones {
+		if now.Sub(state.startedAt) > l.cfg.CodeTTL {
+			delete(l.phones, phone)
+		}
+	}
+
+	for token, phone := range l.tokens {
+		if _, ok := l.phones[phone]; !ok {
+			delete(l.tokens, token)
+		}
+	}
+}
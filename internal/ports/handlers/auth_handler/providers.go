@@ -0,0 +1,96 @@
+package auth_handler
+
+import (
+	"context"
+	"fmt"
+
+	"api-gateway/internal/config"
+)
+
+// OAuthProvider is a login through a third-party account, backed by the
+// auth service.
+type OAuthProvider interface {
+	LoginURL(ctx context.Context) (string, error)
+	Authorize(ctx context.Context, state, code string) (*Tokens, error)
+}
+
+// OAuthProviderFuncs adapts a pair of client methods to OAuthProvider.
+type OAuthProviderFuncs struct {
+	LoginURLFunc  func(ctx context.Context) (string, error)
+	AuthorizeFunc func(ctx context.Context, state, code string) (*Tokens, error)
+}
+
+func (f OAuthProviderFuncs) LoginURL(ctx context.Context) (string, error) {
+	return f.LoginURLFunc(ctx)
+}
+
+func (f OAuthProviderFuncs) Authorize(ctx context.Context, state, code string) (*Tokens, error) {
+	return f.AuthorizeFunc(ctx, state, code)
+}
+
+// ProviderSource looks up the providers the auth service supports.
+type ProviderSource interface {
+	OAuthProvider(name string) (OAuthProvider, bool)
+}
+
+type registeredProvider struct {
+	OAuthProvider
+	name  string
+	title string
+}
+
+// Providers are the OAuth providers enabled in the config, in config order.
+type Providers struct {
+	list   []registeredProvider
+	byName map[string]registeredProvider
+}
+
+// defaultProviders keep configs without an explicit list working.
+var defaultProviders = []config.OAuthProvider{
+	{Name: "google", Title: "Google"},
+	{Name: "yandex", Title: "Yandex"},
+}
+
+func NewProviders(cfg []config.OAuthProvider, source ProviderSource) (*Providers, error) {
+	const op = "auth_handler.NewProviders"
+
+	if len(cfg) == 0 {
+		cfg = defaultProviders
+	}
+
+	p := &Providers{
+		byName: make(map[string]registeredProvider, len(cfg)),
+	}
+
+	for _, pc := range cfg {
+		if _, ok := p.byName[pc.Name]; ok {
+			return nil, fmt.Errorf("%s: provider %q is configured twice", op, pc.Name)
+		}
+
+		provider, ok := source.OAuthProvider(pc.Name)
+		if !ok {
+			return nil, fmt.Errorf("%s: provider %q is not supported", op, pc.Name)
+		}
+
+		title := pc.Title
+		if title == "" {
+			title = pc.Name
+		}
+
+		rp := registeredProvider{
+			OAuthProvider: provider,
+			name:          pc.Name,
+			title:         title,
+		}
+		p.list = append(p.list, rp)
+		p.byName[pc.Name] = rp
+	}
+
+	return p, nil
+}
+
+func (p *Providers) get(name string) (registeredProvider, bool) {
+	rp, ok := p.byName[name]
+
+	return rp, ok
+}
@@ -0,0 +1,49 @@
+package auth_handler
+
+import (
+	"net/http"
+
+	"api-gateway/internal/ports/apierror"
+)
+
+// Session management needs the auth service to list and revoke refresh
+// sessions by ID and to put the session into the access token (a sid
+// claim), so that streams of a revoked session can be closed. The auth
+// service has neither yet. Until it does, the routes are reserved and
+// answer 501, so clients can tell the feature apart from a missing route.
+var errSessionsUnsupported = apierror.New(http.StatusNotImplemented, apierror.CodeNotImplemented,
+	"Session management is not supported by the auth service yet")
+
+// @Summary List sessions
+// @Description Активные сессии пользователя на всех устройствах. Пока auth сервис не отдаёт сессии, отвечает 501
+// @Tags auth
+// @Produce json
+// @Failure 401 {object} apierror.Error
+// @Failure 501 {object} apierror.Error
+// @Router /auth/sessions [get]
+func (c *AuthHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	apierror.Write(w, r, errSessionsUnsupported)
+}
+
+// @Summary Revoke session
+// @Description Завершает сессию на другом устройстве. Пока auth сервис не отдаёт сессии, отвечает 501
+// @Tags auth
+// @Produce json
+// @Param id path string true "Session ID"
+// @Failure 401 {object} apierror.Error
+// @Failure 501 {object} apierror.Error
+// @Router /auth/sessions/{id} [delete]
+func (c *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	apierror.Write(w, r, errSessionsUnsupported)
+}
+
+// @Summary Log out everywhere
+// @Description Завершает все сессии пользователя. Пока auth сервис не отдаёт сессии, отвечает 501
+// @Tags auth
+// @Produce json
+// @Failure 401 {object} apierror.Error
+// @Failure 501 {object} apierror.Error
+// @Router /auth/sessions [delete]
+func (c *AuthHandler) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	apierror.Write(w, r, errSessionsUnsupported)
+}
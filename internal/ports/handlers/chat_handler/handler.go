@@ -9,6 +9,9 @@ import (
 	"time"
 
 	"api-gateway/internal/models"
+	"api-gateway/internal/ports/apierror"
+	"api-gateway/internal/ports/authz"
+	"api-gateway/internal/ports/streams"
 
 	"github.com/go-chi/render"
 	"github.com/gorilla/websocket"
@@ -45,11 +48,13 @@ type ChatClient interface {
 
 type ChatHandler struct {
 	chatClient ChatClient
+	streams    *streams.Registry
 }
 
-func NewChatHandler(chatClient ChatClient) *ChatHandler {
+func NewChatHandler(chatClient ChatClient, streamRegistry *streams.Registry) *ChatHandler {
 	return &ChatHandler{
 		chatClient: chatClient,
+		streams:    streamRegistry,
 	}
 }
 
@@ -57,40 +62,49 @@ func (h *ChatHandler) GetChatList(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	log, err := logger.LoggerFromCtx(ctx)
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
 	uid, ok := ctx.Value(auth.UID).(string)
 	if !ok || uid == "" {
 		log.Error("uid not found in context")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		apierror.Unauthorized(w, r)
 		return
 	}
 	chats, err := h.chatClient.GetChatList(ctx, uid)
 	if err != nil {
 		log.Error("Failed to get chat list", zap.Error(err))
-		http.Error(w, "Failed to get chat list", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "Failed to get chat list")
 		return
 	}
 	render.JSON(w, r, chats)
 }
 
 func (h *ChatHandler) ServeMessages(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithCancel(r.Context())
+	ctx, release := h.streams.Track(r.Context(), streams.KindWebSocket)
+	defer release()
 
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	log, err := logger.LoggerFromCtx(ctx)
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
+		return
+	}
+
+	uid, apiErr := authz.Resolve(r, r.URL.Query().Get("user_id"))
+	if apiErr != nil {
+		log.Warn("Identity mismatch", zap.String("claimed", r.URL.Query().Get("user_id")))
+		apierror.Write(w, r, apiErr)
 		return
 	}
 
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
+		// upgrader has already replied with an HTTP error
 		log.Error("Failed to upgrade connection", zap.Error(err))
-		http.Error(w, "Failed to upgrade connection", http.StatusInternalServerError)
 		return
 	}
 
@@ -120,13 +134,16 @@ func (h *ChatHandler) ServeMessages(w http.ResponseWriter, r *http.Request) {
 
 	outputMessageCh, err := h.chatClient.SendMessage(ctx,
 		r.URL.Query().Get("chat_id"),
-		r.URL.Query().Get("user_id"),
+		uid,
 		r.URL.Query().Get("group_id"),
 		inputMessageCh,
 	)
 	if err != nil {
 		log.Error("Failed to send message", zap.Error(err))
-		http.Error(w, "Failed to send message", http.StatusInternalServerError)
+		closeMsg := websocket.FormatCloseMessage(websocket.CloseInternalServerErr, apierror.FromGRPC(err, "Failed to send message").Message)
+		if err := conn.WriteMessage(websocket.CloseMessage, closeMsg); err != nil {
+			log.Error("Failed to write close message", zap.Error(err))
+		}
 		return
 	}
 
@@ -140,6 +157,12 @@ func (h *ChatHandler) ServeMessages(w http.ResponseWriter, r *http.Request) {
 				log.Error("Failed to write message", zap.Error(err))
 				return
 			}
+		case <-h.streams.Draining():
+			closeMsg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server is shutting down")
+			if err := conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second)); err != nil {
+				log.Error("Failed to write close message", zap.Error(err))
+			}
+			return
 		case <-ctx.Done():
 			return
 		}
@@ -147,14 +170,12 @@ func (h *ChatHandler) ServeMessages(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *ChatHandler) GetMessageEvents(w http.ResponseWriter, r *http.Request) {
-	// ctx, cancel := context.WithCancel(r.Context())
-	// defer cancel()
-
-	ctx := r.Context()
+	ctx, release := h.streams.Track(r.Context(), streams.KindSSE)
+	defer release()
 
 	log, err := logger.LoggerFromCtx(ctx)
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
@@ -163,7 +184,7 @@ func (h *ChatHandler) GetMessageEvents(w http.ResponseWriter, r *http.Request) {
 	uid, ok := ctx.Value(auth.UID).(string)
 	if !ok || uid == "" {
 		log.Error("uid not found in context")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		apierror.Unauthorized(w, r)
 		return
 	}
 
@@ -172,7 +193,7 @@ func (h *ChatHandler) GetMessageEvents(w http.ResponseWriter, r *http.Request) {
 	messageCh, err := h.chatClient.GetMessageEvents(ctx, uid)
 	if err != nil {
 		log.Error("Failed to get message events", zap.Error(err))
-		http.Error(w, "Failed to get message events", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "Failed to get message events")
 		return
 	}
 
@@ -180,16 +201,19 @@ func (h *ChatHandler) GetMessageEvents(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("X-Accel-Buffering", "no")
-	w.Header().Set("Transfer-Encoding", "chunked")
+	// connection-specific headers are forbidden in HTTP/2
+	if r.ProtoMajor == 1 {
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("Transfer-Encoding", "chunked")
+	}
 	w.Header().Set("Access-Control-Expose-Headers", "*")
 
 	log.Info("Set headers")
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
@@ -233,6 +257,12 @@ func (h *ChatHandler) GetMessageEvents(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 			flusher.Flush()
+		case <-h.streams.Draining():
+			if _, err := fmt.Fprintf(w, "event: reconnect\nretry: %d\ndata:\n\n", h.streams.RetryHint().Milliseconds()); err != nil {
+				log.Error("Failed to write reconnect", zap.Error(err))
+			}
+			flusher.Flush()
+			return
 		case <-ctx.Done():
 			log.Info("Context done")
 			return
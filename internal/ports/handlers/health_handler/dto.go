@@ -0,0 +1,15 @@
+package health_handler
+
+type BackendStatus struct {
+	Name     string `json:"name"`
+	State    string `json:"state"`
+	Serving  string `json:"serving,omitempty"`
+	Critical bool   `json:"critical"`
+	Healthy  bool   `json:"healthy"`
+	Error    string `json:"error,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status   string          `json:"status"`
+	Backends []BackendStatus `json:"backends"`
+}
@@ -0,0 +1,158 @@
+// Package health_handler provides liveness and readiness probes of the gateway
+package health_handler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"api-gateway/internal/config"
+
+	"github.com/go-chi/render"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	statusOK       = "ok"
+	statusReady    = "ready"
+	statusNotReady = "not_ready"
+	statusDraining = "draining"
+)
+
+// Backend is a named gRPC connection to one of the backend services.
+type Backend struct {
+	Name string
+	Conn *grpc.ClientConn
+}
+
+type HealthHandler struct {
+	backends []Backend
+	critical map[string]bool
+	cfg      config.Health
+	draining atomic.Bool
+}
+
+func NewHealthHandler(backends []Backend, cfg config.Health) *HealthHandler {
+	critical := make(map[string]bool, len(cfg.CriticalBackends))
+	for _, name := range cfg.CriticalBackends {
+		critical[name] = true
+	}
+
+	return &HealthHandler{
+		backends: backends,
+		critical: critical,
+		cfg:      cfg,
+	}
+}
+
+// Liveness reports that the process is alive.
+func (h *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	render.JSON(w, r, map[string]string{
+		"status": statusOK,
+	})
+}
+
+// SetDraining makes readiness fail so that no new traffic is routed to
+// the gateway while it is shutting down.
+func (h *HealthHandler) SetDraining() {
+	h.draining.Store(true)
+}
+
+// Readiness probes every backend connection. The gateway is ready when
+// all critical backends are healthy.
+func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	if h.draining.Load() {
+		render.Status(r, http.StatusServiceUnavailable)
+		render.JSON(w, r, &ReadinessResponse{
+			Status: statusDraining,
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	statuses := make([]BackendStatus, len(h.backends))
+
+	var wg sync.WaitGroup
+	for i, backend := range h.backends {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i] = h.probe(ctx, backend)
+		}()
+	}
+	wg.Wait()
+
+	response := &ReadinessResponse{
+		Status:   statusReady,
+		Backends: statuses,
+	}
+
+	for _, st := range statuses {
+		if st.Critical && !st.Healthy {
+			response.Status = statusNotReady
+		}
+	}
+
+	if response.Status == statusReady {
+		render.Status(r, http.StatusOK)
+	} else {
+		render.Status(r, http.StatusServiceUnavailable)
+	}
+	render.JSON(w, r, response)
+}
+
+func (h *HealthHandler) probe(ctx context.Context, backend Backend) BackendStatus {
+	st := BackendStatus{
+		Name:     backend.Name,
+		Critical: h.critical[backend.Name],
+	}
+
+	if backend.Conn == nil {
+		st.State = "UNCONFIGURED"
+		st.Error = "client is not initialized"
+		return st
+	}
+
+	state := waitForReady(ctx, backend.Conn)
+	st.State = state.String()
+	st.Healthy = state == connectivity.Ready
+
+	if !h.cfg.CheckProtocol {
+		return st
+	}
+
+	resp, err := healthv1.NewHealthClient(backend.Conn).Check(ctx, &healthv1.HealthCheckRequest{})
+	if err != nil {
+		st.Healthy = false
+		st.Error = err.Error()
+		return st
+	}
+
+	st.Serving = resp.GetStatus().String()
+	st.Healthy = resp.GetStatus() == healthv1.HealthCheckResponse_SERVING
+
+	return st
+}
+
+// waitForReady kicks an idle connection and waits until it becomes ready
+// or the context expires.
+func waitForReady(ctx context.Context, conn *grpc.ClientConn) connectivity.State {
+	state := conn.GetState()
+	if state == connectivity.Idle {
+		conn.Connect()
+	}
+
+	for state != connectivity.Ready && state != connectivity.Shutdown {
+		if !conn.WaitForStateChange(ctx, state) {
+			break
+		}
+		state = conn.GetState()
+	}
+
+	return state
+}
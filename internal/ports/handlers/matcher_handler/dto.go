@@ -32,6 +32,8 @@ type Point struct {
 	Lon float64 `json:"lon"`
 }
 
+// Photos are IDs in requests, presigned URLs or photos.Placeholder* values
+// in responses.
 type Parameters struct {
 	Name           string   `json:"name,omitempty"`
 	Surname        string   `json:"surname,omitempty"`
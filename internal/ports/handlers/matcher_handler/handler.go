@@ -1,7 +1,10 @@
 package matcher_handler
 
 import (
+	"api-gateway/internal/ports/apierror"
+	"api-gateway/internal/ports/authz"
 	models "api-gateway/internal/ports/handlers/user_handler"
+	"api-gateway/internal/ports/photos"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -40,18 +43,19 @@ type MatcherClient interface {
 
 type FileStorageClient interface {
 	UploadPhotos(ctx context.Context, userID string, files []*models.FilePhoto) ([]string, error)
-	GetPhotoURL(ctx context.Context, userID string, photoID string) (string, error)
 }
 
 type MatcherHandler struct {
 	matcherClient     MatcherClient
 	fileStorageClient FileStorageClient
+	photos            *photos.Resolver
 }
 
-func NewMatcherHandler(m MatcherClient, storageClient FileStorageClient) *MatcherHandler {
+func NewMatcherHandler(m MatcherClient, storageClient FileStorageClient, photoResolver *photos.Resolver) *MatcherHandler {
 	return &MatcherHandler{
 		matcherClient:     m,
 		fileStorageClient: storageClient,
+		photos:            photoResolver,
 	}
 }
 
@@ -60,13 +64,13 @@ func NewMatcherHandler(m MatcherClient, storageClient FileStorageClient) *Matche
 func (h *MatcherHandler) CreateForm(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
 	if err := r.ParseMultipartForm(52428800); err != nil {
 		log.Error("Failed to parse formdata", zap.Error(err))
-		http.Error(w, "Invalid formdata", http.StatusBadRequest)
+		apierror.BadRequest(w, r, "Invalid formdata")
 		return
 	}
 
@@ -77,28 +81,35 @@ func (h *MatcherHandler) CreateForm(w http.ResponseWriter, r *http.Request) {
 
 	if err := json.Unmarshal([]byte(r.FormValue("data")), &req); err != nil {
 		log.Error("Failed to parse JSON", zap.Error(err))
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		apierror.BadRequest(w, r, "Invalid JSON")
+		return
+	}
+
+	uid, apiErr := authz.Resolve(r, req.UserID)
+	if apiErr != nil {
+		log.Warn("Identity mismatch", zap.String("claimed", req.UserID))
+		apierror.Write(w, r, apiErr)
 		return
 	}
 
 	sex, err := validateSex(req.Parameters.Sex)
 	if err != nil {
-		http.Error(w, "Invalid sex value"+err.Error(), http.StatusBadRequest)
+		apierror.BadRequest(w, r, "Invalid sex value"+err.Error())
 		return
 	}
 
 	userType, err := validateUserType(req.Parameters.UserType)
 	if err != nil {
-		http.Error(w, "Invalid User Type value"+err.Error(), http.StatusBadRequest)
+		apierror.BadRequest(w, r, "Invalid User Type value"+err.Error())
 		return
 	}
 
 	ctx := r.Context()
 
-	photoIDs, err := h.uploadPhotos(ctx, r, req.UserID)
+	photoIDs, err := h.uploadPhotos(ctx, r, uid)
 	if err != nil {
 		log.Error("Failed to upload photos", zap.Error(err))
-		http.Error(w, "Failed to upload photos", http.StatusBadRequest)
+		apierror.BadRequest(w, r, "Failed to upload photos")
 		return
 	}
 
@@ -108,19 +119,19 @@ func (h *MatcherHandler) CreateForm(w http.ResponseWriter, r *http.Request) {
 
 	protoParams := toProtoParams(req.Parameters, sex, userType)
 
-	if err := h.matcherClient.CreateForm(ctx, req.UserID, protoParams); err != nil {
+	if err := h.matcherClient.CreateForm(ctx, uid, protoParams); err != nil {
 		log.Error("Failed to create Form", zap.Error(err))
-		http.Error(w, "Failed to create Form", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "Failed to create Form")
 		return
 	}
 
-	render.Status(r, http.StatusCreated)
+	w.WriteHeader(http.StatusCreated)
 }
 
 func (h *MatcherHandler) GetFormByUser(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
@@ -130,18 +141,13 @@ func (h *MatcherHandler) GetFormByUser(w http.ResponseWriter, r *http.Request) {
 	form, err := h.matcherClient.GetFormByUser(ctx, uid)
 	if err != nil {
 		log.Error("Failed to get Form", zap.Error(err))
-		http.Error(w, "Failed to get Form", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "Failed to get Form")
 		return
 	}
 
-	for i, photoID := range form.Parameters.Photos {
-		url, err := h.fileStorageClient.GetPhotoURL(ctx, form.UserID, photoID)
-		if err != nil {
-			log.Error("Failed to get presigned URL", zap.Error(err))
-			continue
-		}
-		form.Parameters.Photos[i] = url
-	}
+	var photoBatch photos.Batch
+	photoBatch.AddAll(form.UserID, form.Parameters.Photos)
+	h.photos.Resolve(ctx, "matcher.GetFormByUser", &photoBatch)
 
 	render.Status(r, http.StatusOK)
 	render.JSON(w, r, form)
@@ -150,13 +156,13 @@ func (h *MatcherHandler) GetFormByUser(w http.ResponseWriter, r *http.Request) {
 func (h *MatcherHandler) UpdateForm(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
 	if err := r.ParseMultipartForm(52428800); err != nil {
 		log.Error("Failed to parse formdata", zap.Error(err))
-		http.Error(w, "Invalid formdata", http.StatusBadRequest)
+		apierror.BadRequest(w, r, "Invalid formdata")
 		return
 	}
 
@@ -167,7 +173,14 @@ func (h *MatcherHandler) UpdateForm(w http.ResponseWriter, r *http.Request) {
 
 	if err := json.Unmarshal([]byte(r.FormValue("data")), &req); err != nil {
 		log.Error("Failed to parse JSON", zap.Error(err))
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		apierror.BadRequest(w, r, "Invalid JSON")
+		return
+	}
+
+	uid, apiErr := authz.Resolve(r, req.UserID)
+	if apiErr != nil {
+		log.Warn("Identity mismatch", zap.String("claimed", req.UserID))
+		apierror.Write(w, r, apiErr)
 		return
 	}
 
@@ -177,7 +190,7 @@ func (h *MatcherHandler) UpdateForm(w http.ResponseWriter, r *http.Request) {
 	if req.Parameters.Sex != "" {
 		sex, err = validateSex(req.Parameters.Sex)
 		if err != nil {
-			http.Error(w, "Invalid sex value"+err.Error(), http.StatusBadRequest)
+			apierror.BadRequest(w, r, "Invalid sex value"+err.Error())
 			return
 		}
 	}
@@ -185,17 +198,17 @@ func (h *MatcherHandler) UpdateForm(w http.ResponseWriter, r *http.Request) {
 	if req.Parameters.UserType != "" {
 		userType, err = validateUserType(req.Parameters.UserType)
 		if err != nil {
-			http.Error(w, "Invalid User Type value"+err.Error(), http.StatusBadRequest)
+			apierror.BadRequest(w, r, "Invalid User Type value"+err.Error())
 			return
 		}
 	}
 
 	ctx := r.Context()
 
-	photoIDs, err := h.uploadPhotos(ctx, r, req.UserID)
+	photoIDs, err := h.uploadPhotos(ctx, r, uid)
 	if err != nil {
 		log.Error("Failed to upload photos", zap.Error(err))
-		http.Error(w, "Failed to upload photos", http.StatusBadRequest)
+		apierror.BadRequest(w, r, "Failed to upload photos")
 		return
 	}
 
@@ -205,9 +218,9 @@ func (h *MatcherHandler) UpdateForm(w http.ResponseWriter, r *http.Request) {
 
 	protoParams := toProtoParams(req.Parameters, sex, userType)
 
-	if err := h.matcherClient.UpdateForm(ctx, req.UserID, protoParams); err != nil {
+	if err := h.matcherClient.UpdateForm(ctx, uid, protoParams); err != nil {
 		log.Error("Failed to update Form", zap.Error(err))
-		http.Error(w, "Failed to update Form", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "Failed to update Form")
 		return
 	}
 
@@ -217,20 +230,21 @@ func (h *MatcherHandler) UpdateForm(w http.ResponseWriter, r *http.Request) {
 func (h *MatcherHandler) DeleteForm(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
-	uid := chi.URLParam(r, "uid")
-	if uid == "" {
-		http.Error(w, "User id is required", http.StatusBadRequest)
+	uid, apiErr := authz.Resolve(r, chi.URLParam(r, "uid"))
+	if apiErr != nil {
+		log.Warn("Identity mismatch", zap.String("claimed", chi.URLParam(r, "uid")))
+		apierror.Write(w, r, apiErr)
 		return
 	}
 
 	ctx := r.Context()
 	if err := h.matcherClient.DeleteForm(ctx, uid); err != nil {
 		log.Error("Failed to delete form", zap.Error(err))
-		http.Error(w, "Failed to delete form", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "Failed to delete form")
 		return
 	}
 
@@ -242,7 +256,7 @@ func (h *MatcherHandler) DeleteForm(w http.ResponseWriter, r *http.Request) {
 func (h *MatcherHandler) LeaveGroup(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
@@ -250,13 +264,13 @@ func (h *MatcherHandler) LeaveGroup(w http.ResponseWriter, r *http.Request) {
 	uid, ok := ctx.Value(authorization.UID).(string)
 	if !ok || uid == "" {
 		log.Error("uid not found in context")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		apierror.Unauthorized(w, r)
 		return
 	}
 
 	if err := h.matcherClient.LeaveGroup(ctx, uid); err != nil {
 		log.Error("Failed to leave from group", zap.Error(err))
-		http.Error(w, "Failed to leave from group", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "Failed to leave from group")
 		return
 	}
 
@@ -266,7 +280,7 @@ func (h *MatcherHandler) LeaveGroup(w http.ResponseWriter, r *http.Request) {
 func (h *MatcherHandler) KickGroup(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
@@ -276,13 +290,13 @@ func (h *MatcherHandler) KickGroup(w http.ResponseWriter, r *http.Request) {
 	oid, ok := ctx.Value(authorization.UID).(string)
 	if !ok || oid == "" {
 		log.Error("uid not found in context")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		apierror.Unauthorized(w, r)
 		return
 	}
 
 	if err := h.matcherClient.KickGroup(ctx, oid, uid); err != nil {
 		log.Error("Failed to kick group", zap.Error(err))
-		http.Error(w, "Failed to kick group", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "Failed to kick group")
 		return
 	}
 
@@ -292,7 +306,7 @@ func (h *MatcherHandler) KickGroup(w http.ResponseWriter, r *http.Request) {
 func (h *MatcherHandler) GetGroup(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
@@ -302,18 +316,13 @@ func (h *MatcherHandler) GetGroup(w http.ResponseWriter, r *http.Request) {
 	group, err := h.matcherClient.GetGroup(ctx, gid)
 	if err != nil {
 		log.Error("Failed to get Group", zap.Error(err))
-		http.Error(w, "Failed to get Group", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "Failed to get Group")
 		return
 	}
 
-	for i, photoID := range group.Parameters.Photos {
-		url, err := h.fileStorageClient.GetPhotoURL(ctx, group.Id, photoID)
-		if err != nil {
-			log.Error("Failed to get presigned URL", zap.Error(err))
-			continue
-		}
-		group.Parameters.Photos[i] = url
-	}
+	var photoBatch photos.Batch
+	photoBatch.AddAll(group.Id, group.Parameters.Photos)
+	h.photos.Resolve(ctx, "matcher.GetGroup", &photoBatch)
 
 	render.Status(r, http.StatusOK)
 	render.JSON(w, r, group)
@@ -322,7 +331,7 @@ func (h *MatcherHandler) GetGroup(w http.ResponseWriter, r *http.Request) {
 func (h *MatcherHandler) GetGroupByUser(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
@@ -332,18 +341,13 @@ func (h *MatcherHandler) GetGroupByUser(w http.ResponseWriter, r *http.Request)
 	group, err := h.matcherClient.GetGroupByUser(ctx, uid)
 	if err != nil {
 		log.Error("Failed to get Group by User", zap.Error(err))
-		http.Error(w, "Failed to get Group by User", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "Failed to get Group by User")
 		return
 	}
 
-	for i, photoID := range group.Parameters.Photos {
-		url, err := h.fileStorageClient.GetPhotoURL(ctx, group.Id, photoID)
-		if err != nil {
-			log.Error("Failed to get presigned URL", zap.Error(err))
-			continue
-		}
-		group.Parameters.Photos[i] = url
-	}
+	var photoBatch photos.Batch
+	photoBatch.AddAll(group.Id, group.Parameters.Photos)
+	h.photos.Resolve(ctx, "matcher.GetGroupByUser", &photoBatch)
 
 	render.Status(r, http.StatusOK)
 	render.JSON(w, r, group)
@@ -352,20 +356,21 @@ func (h *MatcherHandler) GetGroupByUser(w http.ResponseWriter, r *http.Request)
 func (h *MatcherHandler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
-	oid := chi.URLParam(r, "oid")
-	if oid == "" {
-		http.Error(w, "Owner id is required", http.StatusBadRequest)
+	oid, apiErr := authz.Resolve(r, chi.URLParam(r, "oid"))
+	if apiErr != nil {
+		log.Warn("Identity mismatch", zap.String("claimed", chi.URLParam(r, "oid")))
+		apierror.Write(w, r, apiErr)
 		return
 	}
 
 	ctx := r.Context()
 	if err := h.matcherClient.DeleteGroup(ctx, oid); err != nil {
 		log.Error("Failed to delete group", zap.Error(err))
-		http.Error(w, "Failed to delete group", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "Failed to delete group")
 		return
 	}
 
@@ -375,7 +380,7 @@ func (h *MatcherHandler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
 func (h *MatcherHandler) ListGroupMembers(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
@@ -385,20 +390,15 @@ func (h *MatcherHandler) ListGroupMembers(w http.ResponseWriter, r *http.Request
 	forms, err := h.matcherClient.ListGroupMembers(ctx, gid)
 	if err != nil {
 		log.Error("Failed to get Members of group", zap.Error(err))
-		http.Error(w, "Failed to get Members of group", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "Failed to get Members of group")
 		return
 	}
 
+	var photoBatch photos.Batch
 	for _, form := range forms {
-		for i, photoID := range form.Parameters.Photos {
-			photoURL, err := h.fileStorageClient.GetPhotoURL(ctx, form.UserID, photoID)
-			if err != nil {
-				log.Error("Failed to get presigned URL", zap.Error(err))
-				continue
-			}
-			form.Parameters.Photos[i] = photoURL
-		}
+		photoBatch.AddAll(form.UserID, form.Parameters.Photos)
 	}
+	h.photos.Resolve(ctx, "matcher.ListGroupMembers", &photoBatch)
 
 	response := &ListGroupMembersResponse{
 		Forms: forms,
@@ -413,7 +413,7 @@ func (h *MatcherHandler) ListGroupMembers(w http.ResponseWriter, r *http.Request
 func (h *MatcherHandler) FindGroups(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
@@ -423,23 +423,15 @@ func (h *MatcherHandler) FindGroups(w http.ResponseWriter, r *http.Request) {
 	GroupsWithScore, err := h.matcherClient.FindGroups(ctx, uid)
 	if err != nil {
 		log.Error("Failed to find Groups", zap.Error(err))
-		http.Error(w, "Failed to find Groups", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "Failed to find Groups")
 		return
 	}
 
+	var photoBatch photos.Batch
 	for _, groupWithScore := range GroupsWithScore {
-		for i, photoID := range groupWithScore.Group.Parameters.Photos {
-			url, err := h.fileStorageClient.GetPhotoURL(ctx, groupWithScore.Group.Id, photoID)
-			if err != nil {
-				log.Error("Failed to get group photo presigned URL",
-					zap.String("photo_id", photoID),
-					zap.String("group_id", groupWithScore.Group.Id),
-					zap.Error(err))
-				continue
-			}
-			groupWithScore.Group.Parameters.Photos[i] = url
-		}
+		photoBatch.AddAll(groupWithScore.Group.Id, groupWithScore.Group.Parameters.Photos)
 	}
+	h.photos.Resolve(ctx, "matcher.FindGroups", &photoBatch)
 
 	response := &FindGroupsResponse{
 		GroupsWithScore: GroupsWithScore,
@@ -454,7 +446,7 @@ func (h *MatcherHandler) FindGroups(w http.ResponseWriter, r *http.Request) {
 func (h *MatcherHandler) GetRequests(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
@@ -464,7 +456,7 @@ func (h *MatcherHandler) GetRequests(w http.ResponseWriter, r *http.Request) {
 	resp, err := h.matcherClient.GetRequests(ctx, gid)
 	if err != nil {
 		log.Error("Failed to get GroupRequest", zap.Error(err))
-		http.Error(w, "Failed to get GroupRequest", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "Failed to get GroupRequest")
 		return
 	}
 
@@ -475,7 +467,7 @@ func (h *MatcherHandler) GetRequests(w http.ResponseWriter, r *http.Request) {
 func (h *MatcherHandler) GetRequestsByUserId(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
@@ -483,14 +475,14 @@ func (h *MatcherHandler) GetRequestsByUserId(w http.ResponseWriter, r *http.Requ
 	uid, ok := ctx.Value(authorization.UID).(string)
 	if !ok || uid == "" {
 		log.Error("uid not found in context")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		apierror.Unauthorized(w, r)
 		return
 	}
 
 	resp, err := h.matcherClient.GetRequestsByUserId(ctx, uid)
 	if err != nil {
 		log.Error("Failed to get GroupRequest by user id", zap.Error(err))
-		http.Error(w, "Failed to get GroupRequest by user id", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "Failed to get GroupRequest by user id")
 		return
 	}
 
@@ -501,7 +493,7 @@ func (h *MatcherHandler) GetRequestsByUserId(w http.ResponseWriter, r *http.Requ
 func (h *MatcherHandler) SendJoinRequest(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
@@ -512,20 +504,27 @@ func (h *MatcherHandler) SendJoinRequest(w http.ResponseWriter, r *http.Request)
 
 	if err := render.DecodeJSON(r.Body, &req); err != nil {
 		log.Error("Failed to decode JSON", zap.Error(err))
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		apierror.BadRequest(w, r, "Invalid JSON")
+		return
+	}
+
+	if req.GroupID == "" {
+		apierror.BadRequest(w, r, "Group id is empty")
 		return
 	}
 
-	if req.UserID == "" || req.GroupID == "" {
-		http.Error(w, "User id or group id is empty", http.StatusBadRequest)
+	uid, apiErr := authz.Resolve(r, req.UserID)
+	if apiErr != nil {
+		log.Warn("Identity mismatch", zap.String("claimed", req.UserID))
+		apierror.Write(w, r, apiErr)
 		return
 	}
 
 	ctx := r.Context()
-	rid, err := h.matcherClient.SendJoinRequest(ctx, req.UserID, req.GroupID)
+	rid, err := h.matcherClient.SendJoinRequest(ctx, uid, req.GroupID)
 	if err != nil {
 		log.Error("Failed to send Join Request", zap.Error(err))
-		http.Error(w, "Failed to send Join Request", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "Failed to send Join Request")
 		return
 	}
 
@@ -538,7 +537,7 @@ func (h *MatcherHandler) SendJoinRequest(w http.ResponseWriter, r *http.Request)
 func (h *MatcherHandler) AcceptJoinRequest(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
@@ -549,19 +548,26 @@ func (h *MatcherHandler) AcceptJoinRequest(w http.ResponseWriter, r *http.Reques
 
 	if err := render.DecodeJSON(r.Body, &req); err != nil {
 		log.Error("Failed to decode JSON", zap.Error(err))
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		apierror.BadRequest(w, r, "Invalid JSON")
 		return
 	}
 
-	if req.OwnerID == "" || req.RequestID == "" {
-		http.Error(w, "Owner id or Request id is empty", http.StatusBadRequest)
+	if req.RequestID == "" {
+		apierror.BadRequest(w, r, "Request id is empty")
+		return
+	}
+
+	oid, apiErr := authz.Resolve(r, req.OwnerID)
+	if apiErr != nil {
+		log.Warn("Identity mismatch", zap.String("claimed", req.OwnerID))
+		apierror.Write(w, r, apiErr)
 		return
 	}
 
 	ctx := r.Context()
-	if err := h.matcherClient.AcceptJoinRequest(ctx, req.OwnerID, req.RequestID); err != nil {
+	if err := h.matcherClient.AcceptJoinRequest(ctx, oid, req.RequestID); err != nil {
 		log.Error("Failed to accept Join Request", zap.Error(err))
-		http.Error(w, "Failed to accept Join Request", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "Failed to accept Join Request")
 		return
 	}
 
@@ -571,7 +577,7 @@ func (h *MatcherHandler) AcceptJoinRequest(w http.ResponseWriter, r *http.Reques
 func (h *MatcherHandler) RejectJoinRequest(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
@@ -582,19 +588,26 @@ func (h *MatcherHandler) RejectJoinRequest(w http.ResponseWriter, r *http.Reques
 
 	if err := render.DecodeJSON(r.Body, &req); err != nil {
 		log.Error("Failed to decode JSON", zap.Error(err))
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		apierror.BadRequest(w, r, "Invalid JSON")
+		return
+	}
+
+	if req.RequestID == "" {
+		apierror.BadRequest(w, r, "Request id is empty")
 		return
 	}
 
-	if req.OwnerID == "" || req.RequestID == "" {
-		http.Error(w, "Owner id or Request id is empty", http.StatusBadRequest)
+	oid, apiErr := authz.Resolve(r, req.OwnerID)
+	if apiErr != nil {
+		log.Warn("Identity mismatch", zap.String("claimed", req.OwnerID))
+		apierror.Write(w, r, apiErr)
 		return
 	}
 
 	ctx := r.Context()
-	if err := h.matcherClient.RejectJoinRequest(ctx, req.OwnerID, req.RequestID); err != nil {
+	if err := h.matcherClient.RejectJoinRequest(ctx, oid, req.RequestID); err != nil {
 		log.Error("Failed to reject Join Request", zap.Error(err))
-		http.Error(w, "Failed to reject Join Request", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "Failed to reject Join Request")
 		return
 	}
 
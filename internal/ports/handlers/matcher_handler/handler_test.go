@@ -0,0 +1,261 @@
+package matcher_handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	authorization "github.com/hesoyamTM/nbf-auth/pkg/auth"
+	"github.com/hesoyamTM/nbf-auth/pkg/logger"
+	matcherv1 "github.com/hesoyamTM/nbf-protos/gen/go/matcher"
+	"go.uber.org/zap"
+)
+
+const (
+	owner    = "user-1"
+	intruder = "user-2"
+)
+
+// fakeMatcher records the user every mutating call is made for.
+type fakeMatcher struct {
+	MatcherClient
+	calls map[string]string
+}
+
+func (f *fakeMatcher) record(method, uid string) {
+	f.calls[method] = uid
+}
+
+func (f *fakeMatcher) CreateForm(_ context.Context, uid string, _ *matcherv1.Parameters) error {
+	f.record("CreateForm", uid)
+	return nil
+}
+
+func (f *fakeMatcher) UpdateForm(_ context.Context, uid string, _ *matcherv1.Parameters) error {
+	f.record("UpdateForm", uid)
+	return nil
+}
+
+func (f *fakeMatcher) DeleteForm(_ context.Context, uid string) error {
+	f.record("DeleteForm", uid)
+	return nil
+}
+
+func (f *fakeMatcher) DeleteGroup(_ context.Context, oid string) error {
+	f.record("DeleteGroup", oid)
+	return nil
+}
+
+func (f *fakeMatcher) SendJoinRequest(_ context.Context, uid, _ string) (string, error) {
+	f.record("SendJoinRequest", uid)
+	return "request-1", nil
+}
+
+func (f *fakeMatcher) AcceptJoinRequest(_ context.Context, oid, _ string) error {
+	f.record("AcceptJoinRequest", oid)
+	return nil
+}
+
+func (f *fakeMatcher) RejectJoinRequest(_ context.Context, oid, _ string) error {
+	f.record("RejectJoinRequest", oid)
+	return nil
+}
+
+type route struct {
+	method  string
+	pattern string
+	handler func(h *MatcherHandler) http.HandlerFunc
+	// status is the status of a successful call.
+	status int
+	// request builds a request in which claimed is the user ID sent by the
+	// client, empty if it sends none.
+	request func(t *testing.T, claimed string) *http.Request
+}
+
+var mutatingRoutes = map[string]route{
+	"CreateForm": {
+		method:  http.MethodPost,
+		pattern: "/api/v1/matcher/form",
+		handler: func(h *MatcherHandler) http.HandlerFunc { return h.CreateForm },
+		status:  http.StatusCreated,
+		request: func(t *testing.T, claimed string) *http.Request {
+			return formRequest(t, http.MethodPost, claimed)
+		},
+	},
+	"UpdateForm": {
+		method:  http.MethodPut,
+		pattern: "/api/v1/matcher/form",
+		handler: func(h *MatcherHandler) http.HandlerFunc { return h.UpdateForm },
+		status:  http.StatusOK,
+		request: func(t *testing.T, claimed string) *http.Request {
+			return formRequest(t, http.MethodPut, claimed)
+		},
+	},
+	"DeleteForm": {
+		method:  http.MethodDelete,
+		pattern: "/api/v1/matcher/form/{uid}",
+		handler: func(h *MatcherHandler) http.HandlerFunc { return h.DeleteForm },
+		status:  http.StatusOK,
+		request: func(t *testing.T, claimed string) *http.Request {
+			return httptest.NewRequest(http.MethodDelete, "/api/v1/matcher/form/"+pathID(claimed), nil)
+		},
+	},
+	"DeleteGroup": {
+		method:  http.MethodDelete,
+		pattern: "/api/v1/matcher/group/{oid}",
+		handler: func(h *MatcherHandler) http.HandlerFunc { return h.DeleteGroup },
+		status:  http.StatusOK,
+		request: func(t *testing.T, claimed string) *http.Request {
+			return httptest.NewRequest(http.MethodDelete, "/api/v1/matcher/group/"+pathID(claimed), nil)
+		},
+	},
+	"SendJoinRequest": {
+		method:  http.MethodPost,
+		pattern: "/api/v1/matcher/group/send",
+		handler: func(h *MatcherHandler) http.HandlerFunc { return h.SendJoinRequest },
+		status:  http.StatusOK,
+		request: func(t *testing.T, claimed string) *http.Request {
+			return jsonRequest(t, "/api/v1/matcher/group/send", map[string]string{
+				"user_id":  claimed,
+				"group_id": "group-1",
+			})
+		},
+	},
+	"AcceptJoinRequest": {
+		method:  http.MethodPost,
+		pattern: "/api/v1/matcher/group/accept",
+		handler: func(h *MatcherHandler) http.HandlerFunc { return h.AcceptJoinRequest },
+		status:  http.StatusOK,
+		request: func(t *testing.T, claimed string) *http.Request {
+			return jsonRequest(t, "/api/v1/matcher/group/accept", map[string]string{
+				"owner_id":   claimed,
+				"request_id": "request-1",
+			})
+		},
+	},
+	"RejectJoinRequest": {
+		method:  http.MethodPost,
+		pattern: "/api/v1/matcher/group/reject",
+		handler: func(h *MatcherHandler) http.HandlerFunc { return h.RejectJoinRequest },
+		status:  http.StatusOK,
+		request: func(t *testing.T, claimed string) *http.Request {
+			return jsonRequest(t, "/api/v1/matcher/group/reject", map[string]string{
+				"owner_id":   claimed,
+				"request_id": "request-1",
+			})
+		},
+	},
+}
+
+func TestMutatingRoutesActAsTokenOwner(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		claimed    string
+		wantStatus int
+		wantCalled bool
+	}{
+		{name: "no claimed id", token: owner, claimed: "", wantCalled: true},
+		{name: "own id", token: owner, claimed: owner, wantCalled: true},
+		{name: "other user id", token: owner, claimed: intruder, wantStatus: http.StatusForbidden},
+		{name: "no token", token: "", claimed: owner, wantStatus: http.StatusUnauthorized},
+	}
+
+	for method, rt := range mutatingRoutes {
+		for _, tt := range tests {
+			// The delete routes always carry an ID in the path.
+			if tt.claimed == "" && rt.method == http.MethodDelete {
+				continue
+			}
+
+			t.Run(method+"/"+tt.name, func(t *testing.T) {
+				matcher := &fakeMatcher{calls: map[string]string{}}
+				h := NewMatcherHandler(matcher, nil, nil)
+
+				router := chi.NewRouter()
+				router.MethodFunc(rt.method, rt.pattern, rt.handler(h))
+
+				req := rt.request(t, tt.claimed)
+				ctx := context.WithValue(req.Context(), logger.CtxKey, zap.NewNop())
+				if tt.token != "" {
+					ctx = context.WithValue(ctx, authorization.UID, tt.token)
+				}
+
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req.WithContext(ctx))
+
+				want := tt.wantStatus
+				if tt.wantCalled {
+					want = rt.status
+				}
+				if rec.Code != want {
+					t.Fatalf("status = %d, want %d, body %s", rec.Code, want, rec.Body)
+				}
+
+				uid, called := matcher.calls[method]
+				if called != tt.wantCalled {
+					t.Fatalf("backend called = %v, want %v", called, tt.wantCalled)
+				}
+				if called && uid != tt.token {
+					t.Fatalf("backend called for %q, want token owner %q", uid, tt.token)
+				}
+			})
+		}
+	}
+}
+
+func pathID(claimed string) string {
+	if claimed == "" {
+		return owner
+	}
+
+	return claimed
+}
+
+func formRequest(t *testing.T, method, claimed string) *http.Request {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]any{
+		"user_id": claimed,
+		"parameters": map[string]any{
+			"sex":       "male",
+			"user_type": "student",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if err := mw.WriteField("data", string(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(method, "/api/v1/matcher/form", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	return req
+}
+
+func jsonRequest(t *testing.T, target string, payload map[string]string) *http.Request {
+	t.Helper()
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	return req
+}
@@ -7,6 +7,8 @@ import (
 	"net/http"
 
 	"api-gateway/internal/models"
+	"api-gateway/internal/ports/apierror"
+	"api-gateway/internal/ports/streams"
 
 	"github.com/go-chi/render"
 	"github.com/hesoyamTM/nbf-auth/pkg/auth"
@@ -22,11 +24,13 @@ type NotificationService interface {
 
 type NotificationHandler struct {
 	notificationService NotificationService
+	streams             *streams.Registry
 }
 
-func NewNotificationHandler(notificationService NotificationService) *NotificationHandler {
+func NewNotificationHandler(notificationService NotificationService, streamRegistry *streams.Registry) *NotificationHandler {
 	return &NotificationHandler{
 		notificationService: notificationService,
+		streams:             streamRegistry,
 	}
 }
 
@@ -35,21 +39,21 @@ func (h *NotificationHandler) GetNotifications(w http.ResponseWriter, r *http.Re
 
 	log, err := logger.LoggerFromCtx(ctx)
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
 	uid, ok := ctx.Value(auth.UID).(string)
 	if !ok || uid == "" {
 		log.Error("uid not found in context")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		apierror.Unauthorized(w, r)
 		return
 	}
 
 	notifications, err := h.notificationService.GetNotificationList(ctx, uid)
 	if err != nil {
 		log.Error("Failed to get Notifications", zap.Error(err))
-		http.Error(w, "Failed to get Notifications", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "Failed to get Notifications")
 		return
 	}
 
@@ -62,20 +66,20 @@ func (h *NotificationHandler) ReadNotification(w http.ResponseWriter, r *http.Re
 
 	log, err := logger.LoggerFromCtx(ctx)
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
 	var req ReadNotificationsRequest
 	if err := render.DecodeJSON(r.Body, &req); err != nil {
 		log.Error("Failed to decode JSON", zap.Error(err))
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		apierror.BadRequest(w, r, "Invalid JSON")
 		return
 	}
 
 	if err := h.notificationService.ReadNotifications(ctx, req.IDs); err != nil {
 		log.Error("Failed to read notification", zap.Error(err))
-		http.Error(w, "Failed to read notification", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "Failed to read notification")
 		return
 	}
 
@@ -83,38 +87,39 @@ func (h *NotificationHandler) ReadNotification(w http.ResponseWriter, r *http.Re
 }
 
 func (h *NotificationHandler) GettingNotifications(w http.ResponseWriter, r *http.Request) {
-	// ctx, cancel := context.WithCancel(r.Context())
-	// defer cancel()
-
-	ctx := r.Context()
+	ctx, release := h.streams.Track(r.Context(), streams.KindSSE)
+	defer release()
 
 	log, err := logger.LoggerFromCtx(ctx)
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
 	uid, ok := ctx.Value(auth.UID).(string)
 	if !ok || uid == "" {
 		log.Error("uid not found in context")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		apierror.Unauthorized(w, r)
 		return
 	}
 
 	notificationCh, err := h.notificationService.GettingNotification(ctx, uid)
 	if err != nil {
 		log.Error("Failed to get notification", zap.Error(err))
-		http.Error(w, "Failed to get notification", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "Failed to get notification")
 		return
 	}
 
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
+	// connection-specific headers are forbidden in HTTP/2
+	if r.ProtoMajor == 1 {
+		w.Header().Set("Connection", "keep-alive")
+	}
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
@@ -143,6 +148,12 @@ func (h *NotificationHandler) GettingNotifications(w http.ResponseWriter, r *htt
 				return
 			}
 			flusher.Flush()
+		case <-h.streams.Draining():
+			if _, err := fmt.Fprintf(w, "event: reconnect\nretry: %d\ndata:\n\n", h.streams.RetryHint().Milliseconds()); err != nil {
+				log.Error("Failed to write reconnect", zap.Error(err))
+			}
+			flusher.Flush()
+			return
 		case <-ctx.Done():
 			return
 		}
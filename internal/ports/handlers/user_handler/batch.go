@@ -0,0 +1,190 @@
+package user_handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"api-gateway/internal/ports/apierror"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fallbackConcurrency bounds the per-ID lookups when the batch call fails.
+const fallbackConcurrency = 8
+
+// userFields are the names accepted by ?fields=, in output order. They are
+// the JSON names of User.
+var userFields = []string{"id", "name", "surname", "contacts", "avatar", "description"}
+
+// parseIDs splits, trims and deduplicates the comma-separated IDs, keeping
+// the order of the first occurrence.
+func parseIDs(params ...string) []string {
+	seen := make(map[string]struct{})
+
+	var ids []string
+	for _, param := range params {
+		for _, id := range strings.Split(param, ",") {
+			id = strings.TrimSpace(id)
+			if id == "" {
+				continue
+			}
+			if _, ok := seen[id]; ok {
+				continue
+			}
+
+			seen[id] = struct{}{}
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+func validateIDs(ids []string, maxBatch int) []apierror.FieldError {
+	var fields []apierror.FieldError
+
+	if len(ids) == 0 {
+		fields = append(fields, apierror.FieldError{Field: "ids", Message: "is required"})
+	}
+	if maxBatch > 0 && len(ids) > maxBatch {
+		fields = append(fields, apierror.FieldError{Field: "ids", Message: fmt.Sprintf("must contain at most %d IDs", maxBatch)})
+	}
+
+	for _, id := range ids {
+		if _, err := uuid.Parse(id); err != nil {
+			fields = append(fields, apierror.FieldError{Field: "ids", Message: fmt.Sprintf("%q is not a valid user ID", id)})
+		}
+	}
+
+	return fields
+}
+
+// parseFields returns the requested fields, all of them if none are given.
+// id is always included.
+func parseFields(param string) ([]string, []apierror.FieldError) {
+	if strings.TrimSpace(param) == "" {
+		return userFields, nil
+	}
+
+	requested := map[string]bool{"id": true}
+
+	var errs []apierror.FieldError
+	for _, field := range strings.Split(param, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if field == "" {
+			continue
+		}
+		if !slices.Contains(userFields, field) {
+			errs = append(errs, apierror.FieldError{Field: "fields", Message: fmt.Sprintf("unknown field %q", field)})
+			continue
+		}
+
+		requested[field] = true
+	}
+
+	fields := make([]string, 0, len(requested))
+	for _, field := range userFields {
+		if requested[field] {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields, errs
+}
+
+// project keeps the requested fields of the user as encoded by GET
+// /user/{uid}, so that both endpoints return the same shape.
+func project(user *User, fields []string) (map[string]any, error) {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return nil, err
+	}
+
+	var encoded map[string]json.RawMessage
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return nil, err
+	}
+
+	card := make(map[string]any, len(fields))
+	for _, field := range fields {
+		if value, ok := encoded[field]; ok {
+			card[field] = value
+		}
+	}
+
+	return card, nil
+}
+
+// lookupUsers fetches the users in one call. If the user service fails the
+// whole batch because of an unknown ID, the users are fetched one by one.
+// Users are returned in the order of ids together with the missing IDs.
+func (h *UserHandler) lookupUsers(ctx context.Context, ids []string) ([]*User, []string, error) {
+	users, err := h.userClient.GetUsers(ctx, ids)
+	if status.Code(err) == codes.NotFound {
+		users, err = h.lookupEach(ctx, ids)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byID := make(map[string]*User, len(users))
+	for _, user := range users {
+		if user != nil {
+			byID[user.ID] = user
+		}
+	}
+
+	found := make([]*User, 0, len(ids))
+	missing := make([]string, 0)
+	for _, id := range ids {
+		if user, ok := byID[id]; ok {
+			found = append(found, user)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+
+	return found, missing, nil
+}
+
+func (h *UserHandler) lookupEach(ctx context.Context, ids []string) ([]*User, error) {
+	users := make([]*User, len(ids))
+	errs := make([]error, len(ids))
+
+	sem := make(chan struct{}, fallbackConcurrency)
+	var wg sync.WaitGroup
+
+	for i, id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			user, err := h.userClient.GetUser(ctx, id)
+			if status.Code(err) == codes.NotFound {
+				return
+			}
+
+			users[i], errs[i] = user, err
+		}()
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return users, nil
+}
@@ -1,29 +1,117 @@
 package user_handler
 
-import "io"
+import (
+	"io"
+	"time"
 
+	"api-gateway/internal/jobs"
+)
+
+// @Description User profile. /users returns the same fields, limited to the requested ones
 type User struct {
-	ID          string
-	Name        string
-	Surname     string
-	Contacts    []string
-	Avatar      string
-	Description string
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Surname     string   `json:"surname"`
+	Contacts    []string `json:"contacts"`
+	Avatar      string   `json:"avatar"`
+	Description string   `json:"description"`
 } // @name User
 
-// @Description Get users response
+// @Description Found users with the requested fields and the IDs that do not exist
 type GetUsersResponse struct {
-	users []*User
+	Users   []map[string]any `json:"users"`
+	Missing []string         `json:"missing"`
 } // @name GetUsersResponse
 
 // @Description Update user request
 type UpdateUserRequest struct {
-	Name        string
-	Surname     string
-	Contacts    []string
-	Description string
+	Name        string   `json:"name"`
+	Surname     string   `json:"surname"`
+	Contacts    []string `json:"contacts"`
+	Description string   `json:"description"`
 } // @name UpdateUserRequest
 
+// @Description Avatar after upload
+type AvatarResponse struct {
+	Avatar string `json:"avatar"`
+} // @name AvatarResponse
+
+// Fields of UserUpdate.Mask and of validation errors, the JSON names of
+// User.
+const (
+	FieldName        = "name"
+	FieldSurname     = "surname"
+	FieldContacts    = "contacts"
+	FieldAvatar      = "avatar"
+	FieldDescription = "description"
+)
+
+// UserUpdate writes the fields of User listed in Mask, the other fields
+// are left as they are.
+type UserUpdate struct {
+	User User
+	Mask []string
+}
+
+// @Description Account deletion request of clients that do not use cookies
+type DeleteUserRequest struct {
+	RefreshToken string `json:"refresh_token"`
+} // @name DeleteUserRequest
+
+// @Description Progress of an account deletion. Skipped steps are not supported by a backend or had nothing to do. Steps and deletions in cleanup_pending left data in a backend that cannot remove it
+type DeletionResponse struct {
+	ID        string    `json:"id"`
+	Status    string    `json:"status"`
+	Steps     []JobStep `json:"steps"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+} // @name DeletionResponse
+
+// @Description Progress of a data export. The archive can be downloaded from download_url until expires_at
+type ExportResponse struct {
+	ID          string     `json:"id"`
+	Status      string     `json:"status"`
+	Steps       []JobStep  `json:"steps"`
+	Error       string     `json:"error,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+} // @name ExportResponse
+
+type JobStep struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Message   string    `json:"message,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+} // @name JobStep
+
+func jobSteps(job *jobs.Job) []JobStep {
+	steps := make([]JobStep, len(job.Steps))
+	for i, step := range job.Steps {
+		steps[i] = JobStep{
+			Name:      step.Name,
+			Status:    string(step.Status),
+			Message:   step.Message,
+			UpdatedAt: step.UpdatedAt,
+		}
+	}
+
+	return steps
+}
+
+func deletionResponse(job *jobs.Job) DeletionResponse {
+	return DeletionResponse{
+		ID:        job.ID,
+		Status:    string(job.Status),
+		Steps:     jobSteps(job),
+		Error:     job.Error,
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+	}
+}
+
 type FilePhoto struct {
 	Data        io.Reader `json:"data"`
 	FileName    string    `json:"file_name"`
@@ -3,8 +3,19 @@ package user_handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
-	"strings"
+	"os"
+	"slices"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/jobs"
+	"api-gateway/internal/ports/apierror"
+	"api-gateway/internal/ports/cookies"
+	"api-gateway/internal/ports/photos"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/render"
@@ -17,25 +28,69 @@ type UserClient interface {
 	CreateUser(ctx context.Context, NewUser *User) error
 	GetUser(ctx context.Context, UserID string) (*User, error)
 	GetUsers(ctx context.Context, ids []string) ([]*User, error)
-	UpdateUser(ctx context.Context, UserModel *User) error
+	UpdateUserFields(ctx context.Context, update *UserUpdate) (*User, error)
 	DeleteUser(ctx context.Context, UserID string) error
 }
 
+// AccountDeleter runs account deletions in the background.
+type AccountDeleter interface {
+	Start(ctx context.Context, uid, refreshToken string) (*jobs.Job, error)
+	Status(uid string) (*jobs.Job, error)
+}
+
+// DataExporter assembles data exports in the background.
+type DataExporter interface {
+	Start(ctx context.Context, uid string) (*jobs.Job, error)
+	Get(uid, id string) (*jobs.Job, error)
+	Open(uid, id string) (*os.File, *jobs.Job, error)
+	ExpiresAt(job *jobs.Job) time.Time
+}
+
 type FileStorageClient interface {
 	UploadAvatar(ctx context.Context, userID string, file *FilePhoto) (string, error)
-	GetPhotoURL(ctx context.Context, userID string, photoID string) (string, error)
 }
 
+const (
+	maxAvatarSize = 10 << 20
+	// maxFormOverhead is room for the data field and the multipart framing.
+	maxFormOverhead = 1 << 20
+	maxPatchSize    = 64 << 10
+)
+
+var errInvalidAvatar = errors.New("invalid avatar")
+
 type UserHandler struct {
 	userClient        UserClient
 	fileStorageClient FileStorageClient
+	photos            *photos.Resolver
+	deletion          AccountDeleter
+	export            DataExporter
+	cookies           *cookies.Issuer
+	cfg               config.Users
 }
 
-func NewUserHandler(userClient UserClient, storageClient FileStorageClient) *UserHandler {
+func NewUserHandler(userClient UserClient, storageClient FileStorageClient, photoResolver *photos.Resolver, deletion AccountDeleter, export DataExporter, cookieIssuer *cookies.Issuer, cfg config.Users) *UserHandler {
 	return &UserHandler{
 		userClient:        userClient,
 		fileStorageClient: storageClient,
+		photos:            photoResolver,
+		deletion:          deletion,
+		export:            export,
+		cookies:           cookieIssuer,
+		cfg:               cfg,
+	}
+}
+
+// resolveAvatars replaces avatar IDs with presigned URLs or placeholders.
+func (h *UserHandler) resolveAvatars(ctx context.Context, operation string, users ...*User) {
+	var batch photos.Batch
+	for _, user := range users {
+		if user != nil {
+			batch.Add(user.ID, &user.Avatar)
+		}
 	}
+
+	h.photos.Resolve(ctx, "user."+operation, &batch)
 }
 
 // @Summary Get session
@@ -52,7 +107,7 @@ func NewUserHandler(userClient UserClient, storageClient FileStorageClient) *Use
 func (c *UserHandler) GetSession(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
@@ -60,24 +115,17 @@ func (c *UserHandler) GetSession(w http.ResponseWriter, r *http.Request) {
 	uid, ok := ctx.Value(authorization.UID).(string)
 	if !ok || uid == "" {
 		log.Error("uid not found in context")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		apierror.Unauthorized(w, r)
 		return
 	}
 	user, err := c.userClient.GetUser(ctx, uid)
 	if err != nil {
 		log.Error("Failed to authorize user", zap.Error(err))
-		http.Error(w, "User not found", http.StatusNotFound)
+		apierror.GRPC(w, r, err, "Failed to get user")
 		return
 	}
 
-	// if user.Avatar != "" {
-	// 	url, err := c.fileStorageClient.GetPhotoURL(ctx, user.ID, user.Avatar)
-	// 	if err != nil {
-	// 		log.Error("Failed to get presigned url", zap.Error(err))
-	// 		url = ""
-	// 	}
-	// 	user.Avatar = url
-	// }
+	c.resolveAvatars(ctx, "GetSession", user)
 
 	render.JSON(w, r, user)
 }
@@ -94,7 +142,7 @@ func (c *UserHandler) GetSession(w http.ResponseWriter, r *http.Request) {
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
@@ -103,12 +151,12 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	if err := render.DecodeJSON(r.Body, &req); err != nil {
 		log.Error("Failed to decode JSON", zap.Error(err))
 
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		apierror.BadRequest(w, r, "Invalid JSON")
 		return
 	}
 
 	if req.Name == "" || req.Surname == "" {
-		http.Error(w, "Name or surname is empty", http.StatusBadRequest)
+		apierror.BadRequest(w, r, "Name or surname is empty")
 		return
 	}
 
@@ -123,7 +171,7 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Error("Failed to create user", zap.Error(err))
 
-		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "Failed to create user")
 		return
 	}
 
@@ -144,7 +192,7 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
@@ -155,18 +203,11 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Error("Failed to get user", zap.Error(err))
 
-		http.Error(w, "User not found", http.StatusNotFound)
+		apierror.GRPC(w, r, err, "Failed to get user")
 		return
 	}
 
-	// if user.Avatar != "" {
-	// 	url, err := h.fileStorageClient.GetPhotoURL(ctx, user.ID, user.Avatar)
-	// 	if err != nil {
-	// 		log.Error("Failed to get presigned url", zap.Error(err))
-	// 		url = ""
-	// 	}
-	// 	user.Avatar = url
-	// }
+	h.resolveAvatars(ctx, "GetUser", user)
 
 	render.JSON(w, r, user)
 
@@ -174,87 +215,94 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Get users
-// @Description Найти несколько пользователей по ID
+// @Description Найти несколько пользователей по ID. Неизвестные ID возвращаются в missing
 // @Tags user
 // @Accept json
 // @Produce json
-// @Param uids query string true "Comma-separated user IDs" example("id1,id2,id3")
+// @Param ids query string true "Comma-separated user IDs" example("id1,id2,id3")
+// @Param uids query string false "Alias of ids"
+// @Param fields query string false "Comma-separated fields to return: id,name,surname,contacts,avatar,description" example("id,name,avatar")
 // @Success 200 {object} GetUsersResponse
-// @Failure 400
-// @Failure 500
+// @Failure 400 {object} apierror.Error
+// @Failure 500 {object} apierror.Error
 // @Router /users [get]
 func (h *UserHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
-	uidsParam := r.URL.Query().Get("ids")
-	uids := strings.Split(uidsParam, ",")
+	query := r.URL.Query()
+
+	uids := parseIDs(query.Get("ids"), query.Get("uids"))
+	fieldErrs := validateIDs(uids, h.cfg.MaxBatchSize)
 
-	if len(uids) == 0 {
-		log.Error("No valid user IDs provided")
+	fields, errs := parseFields(query.Get("fields"))
+	fieldErrs = append(fieldErrs, errs...)
 
-		http.Error(w, "No valid user IDs provided", http.StatusBadRequest)
+	if len(fieldErrs) > 0 {
+		apierror.BadRequest(w, r, "Invalid users request", fieldErrs...)
 		return
 	}
 
 	ctx := r.Context()
 
-	users, err := h.userClient.GetUsers(ctx, uids)
+	users, missing, err := h.lookupUsers(ctx, uids)
 	if err != nil {
 		log.Error("Failed to get users", zap.Error(err))
 
-		http.Error(w, "Failed to get users", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "Failed to get users")
 		return
 	}
 
-	// var result []*User
-
-	// for _, user := range users {
-	// 	if user.Avatar != "" {
-	// 		url, err := h.fileStorageClient.GetPhotoURL(ctx, user.ID, user.Avatar)
-	// 		if err != nil {
-	// 			log.Error("Failed to get presigned url", zap.Error(err))
-	// 			url = ""
-	// 		}
+	if slices.Contains(fields, "avatar") {
+		h.resolveAvatars(ctx, "GetUsers", users...)
+	}
 
-	// 		user.Avatar = url
-	// 	}
+	response := &GetUsersResponse{
+		Users:   make([]map[string]any, 0, len(users)),
+		Missing: missing,
+	}
+	for _, user := range users {
+		card, err := project(user, fields)
+		if err != nil {
+			log.Error("Failed to encode user", zap.Error(err))
 
-	// 	result = append(result, user)
-	// }
+			apierror.Internal(w, r, "Failed to get users")
+			return
+		}
 
-	response := &GetUsersResponse{
-		users: users,
+		response.Users = append(response.Users, card)
 	}
 
-	render.JSON(w, r, response)
 	render.Status(r, http.StatusOK)
+	render.JSON(w, r, response)
 }
 
 // @Summary Update user
-// @Description Обновить данные пользователя пользователя в системе
+// @Description Заменить имя, фамилию, контакты и описание профиля. Аватар меняется, только если передан файл avatar. Профиль записывается через чтение и запись, при одновременных изменениях побеждает последнее
 // @Tags user
-// @Accept json
-// @Param uid path string true "User ID"
-// @Param request body UpdateUserRequest true "User data"
+// @Accept multipart/form-data
+// @Param data formData string true "UpdateUserRequest as JSON"
+// @Param avatar formData file false "New avatar"
 // @Success 200
-// @Failure 400
-// @Failure 401
-// @Failure 500
+// @Failure 400 {object} apierror.Error
+// @Failure 401 {object} apierror.Error
+// @Failure 413 {object} apierror.Error
+// @Failure 422 {object} apierror.Error
+// @Failure 500 {object} apierror.Error
 // @Router /user [put]
 func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
-	if err := r.ParseMultipartForm(10485760); err != nil {
+	if err := parseAvatarForm(w, r); err != nil {
 		log.Error("Failed to parse formdata", zap.Error(err))
-		http.Error(w, "Invalid formdata", http.StatusBadRequest)
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -262,7 +310,7 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	uid, ok := ctx.Value(authorization.UID).(string)
 	if !ok || uid == "" {
 		log.Error("uid not found in context", zap.Error(err))
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		apierror.Unauthorized(w, r)
 		return
 	}
 
@@ -270,67 +318,346 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 
 	if err := json.Unmarshal([]byte(r.FormValue("data")), &req); err != nil {
 		log.Error("Failed to decode JSON", zap.Error(err))
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		apierror.BadRequest(w, r, "Invalid JSON")
 		return
 	}
 
-	// взаимодействие с s3
-	var avatar = "-1"
-	file, metadata, err := r.FormFile("avatar")
-	if err == nil {
-		defer file.Close()
+	update := &UserUpdate{
+		User: User{
+			ID:          uid,
+			Name:        req.Name,
+			Surname:     req.Surname,
+			Contacts:    req.Contacts,
+			Description: req.Description,
+		},
+		Mask: []string{FieldName, FieldSurname, FieldContacts, FieldDescription},
+	}
+	if fields := validateUpdate(update); len(fields) > 0 {
+		apierror.Unprocessable(w, r, "Invalid profile", fields...)
+		return
+	}
 
-		log.Info("Uploading avatar",
-			zap.String("user_id", uid),
-			zap.String("filename", metadata.Filename),
-			zap.Int64("file_size", metadata.Size),
-			zap.String("content_type", metadata.Header.Get("Content-Type")))
+	avatar, uploaded, err := h.uploadAvatar(r, uid)
+	if errors.Is(err, errInvalidAvatar) {
+		log.Warn("Invalid avatar", zap.Error(err))
+		apierror.BadRequest(w, r, "Invalid avatar",
+			apierror.FieldError{Field: FieldAvatar, Message: err.Error()})
+		return
+	}
+	if err != nil {
+		log.Error("Failed to upload avatar", zap.Error(err))
+		apierror.GRPC(w, r, err, "Failed to upload avatar")
+		return
+	}
+	if uploaded {
+		update.User.Avatar = avatar
+		update.Mask = append(update.Mask, FieldAvatar)
+	}
 
-		avatar, err = h.fileStorageClient.UploadAvatar(ctx, uid, &FilePhoto{
-			Data:        file,
-			FileName:    metadata.Filename,
-			ContentType: metadata.Header.Get("Content-Type"),
-		})
-		if err != nil {
-			log.Error("Failed to upload avatar", zap.Error(err), zap.String("grpc_error", err.Error()))
-			http.Error(w, "Failed to upload avatar: "+err.Error(), http.StatusInternalServerError)
+	if _, err := h.userClient.UpdateUserFields(ctx, update); err != nil {
+		log.Error("Failed to update user", zap.Error(err))
+
+		apierror.GRPC(w, r, err, "Failed to update user")
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+}
+
+// @Summary Patch user
+// @Description Частично обновить профиль (JSON Merge Patch). Отсутствующие поля не меняются, null очищает поле. Пока у user сервиса нет маски полей, профиль записывается через чтение и запись, при одновременных изменениях побеждает последнее
+// @Tags user
+// @Accept json
+// @Accept application/merge-patch+json
+// @Produce json
+// @Param request body UpdateUserRequest true "Fields to change"
+// @Success 200 {object} User
+// @Failure 400 {object} apierror.Error
+// @Failure 401 {object} apierror.Error
+// @Failure 413 {object} apierror.Error
+// @Failure 422 {object} apierror.Error
+// @Failure 500 {object} apierror.Error
+// @Router /user [patch]
+func (h *UserHandler) PatchUser(w http.ResponseWriter, r *http.Request) {
+	log, err := logger.LoggerFromCtx(r.Context())
+	if err != nil {
+		apierror.Internal(w, r, "Internal error")
+		return
+	}
+
+	ctx := r.Context()
+	uid, ok := ctx.Value(authorization.UID).(string)
+	if !ok || uid == "" {
+		log.Error("uid not found in context")
+		apierror.Unauthorized(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			apierror.Write(w, r, apierror.New(http.StatusRequestEntityTooLarge, apierror.CodeTooLarge, "Request is too large"))
 			return
 		}
 
+		apierror.BadRequest(w, r, "Failed to read body")
+		return
+	}
+
+	update, fields, err := parseMergePatch(uid, body)
+	if err != nil {
+		log.Error("Failed to decode merge patch", zap.Error(err))
+		apierror.BadRequest(w, r, "Invalid JSON")
+		return
 	}
 
-	err = h.userClient.UpdateUser(ctx, &User{
-		ID:          uid,
-		Name:        req.Name,
-		Surname:     req.Surname,
-		Contacts:    req.Contacts,
-		Avatar:      avatar,
-		Description: req.Description,
+	fields = append(fields, validateUpdate(update)...)
+	if len(fields) > 0 {
+		apierror.Unprocessable(w, r, "Invalid profile", fields...)
+		return
+	}
+
+	var user *User
+	if len(update.Mask) == 0 {
+		user, err = h.userClient.GetUser(ctx, uid)
+	} else {
+		user, err = h.userClient.UpdateUserFields(ctx, update)
+	}
+	if err != nil {
+		log.Error("Failed to update user", zap.Error(err))
+
+		apierror.GRPC(w, r, err, "Failed to update user")
+		return
+	}
+
+	h.resolveAvatars(ctx, "PatchUser", user)
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, user)
+}
+
+// @Summary Upload avatar
+// @Description Загрузить новый аватар
+// @Tags user
+// @Accept multipart/form-data
+// @Produce json
+// @Param avatar formData file true "Avatar"
+// @Success 200 {object} AvatarResponse
+// @Failure 400 {object} apierror.Error
+// @Failure 401 {object} apierror.Error
+// @Failure 413 {object} apierror.Error
+// @Failure 500 {object} apierror.Error
+// @Router /user/avatar [put]
+func (h *UserHandler) PutAvatar(w http.ResponseWriter, r *http.Request) {
+	log, err := logger.LoggerFromCtx(r.Context())
+	if err != nil {
+		apierror.Internal(w, r, "Internal error")
+		return
+	}
+
+	ctx := r.Context()
+	uid, ok := ctx.Value(authorization.UID).(string)
+	if !ok || uid == "" {
+		log.Error("uid not found in context")
+		apierror.Unauthorized(w, r)
+		return
+	}
+
+	if err := parseAvatarForm(w, r); err != nil {
+		log.Error("Failed to parse formdata", zap.Error(err))
+		apierror.Write(w, r, err)
+		return
+	}
+
+	avatar, uploaded, err := h.uploadAvatar(r, uid)
+	if errors.Is(err, errInvalidAvatar) {
+		log.Warn("Invalid avatar", zap.Error(err))
+		apierror.BadRequest(w, r, "Invalid avatar",
+			apierror.FieldError{Field: FieldAvatar, Message: err.Error()})
+		return
+	}
+	if err != nil {
+		log.Error("Failed to upload avatar", zap.Error(err))
+		apierror.GRPC(w, r, err, "Failed to upload avatar")
+		return
+	}
+	if !uploaded {
+		apierror.BadRequest(w, r, "Avatar file is required",
+			apierror.FieldError{Field: FieldAvatar, Message: "is required"})
+		return
+	}
+
+	user, err := h.userClient.UpdateUserFields(ctx, &UserUpdate{
+		User: User{ID: uid, Avatar: avatar},
+		Mask: []string{FieldAvatar},
 	})
 	if err != nil {
 		log.Error("Failed to update user", zap.Error(err))
 
-		http.Error(w, "Failed to update user", http.StatusInternalServerError)
+		apierror.GRPC(w, r, err, "Failed to update user")
 		return
 	}
 
+	h.resolveAvatars(ctx, "PutAvatar", user)
+
 	render.Status(r, http.StatusOK)
+	render.JSON(w, r, AvatarResponse{Avatar: user.Avatar})
+}
+
+// @Summary Delete avatar
+// @Description Удалить аватар пользователя
+// @Tags user
+// @Success 204
+// @Failure 401 {object} apierror.Error
+// @Failure 500 {object} apierror.Error
+// @Router /user/avatar [delete]
+func (h *UserHandler) DeleteAvatar(w http.ResponseWriter, r *http.Request) {
+	log, err := logger.LoggerFromCtx(r.Context())
+	if err != nil {
+		apierror.Internal(w, r, "Internal error")
+		return
+	}
+
+	ctx := r.Context()
+	uid, ok := ctx.Value(authorization.UID).(string)
+	if !ok || uid == "" {
+		log.Error("uid not found in context")
+		apierror.Unauthorized(w, r)
+		return
+	}
+
+	// the file storage cannot delete objects, the photo is only unlinked
+	_, err = h.userClient.UpdateUserFields(ctx, &UserUpdate{
+		User: User{ID: uid},
+		Mask: []string{FieldAvatar},
+	})
+	if err != nil {
+		log.Error("Failed to update user", zap.Error(err))
 
+		apierror.GRPC(w, r, err, "Failed to update user")
+		return
+	}
+
+	render.NoContent(w, r)
+}
+
+// parseAvatarForm parses the multipart form of a request that may carry an
+// avatar. The body is limited to the avatar size and the form overhead.
+func parseAvatarForm(w http.ResponseWriter, r *http.Request) *apierror.Error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxAvatarSize+maxFormOverhead)
+	if err := r.ParseMultipartForm(maxAvatarSize); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return apierror.New(http.StatusRequestEntityTooLarge, apierror.CodeTooLarge, "Request is too large")
+		}
+
+		return apierror.New(http.StatusBadRequest, apierror.CodeBadRequest, "Invalid formdata")
+	}
+
+	return nil
+}
+
+// uploadAvatar uploads the avatar file of the multipart form, if there is
+// one, and returns its photo ID. A malformed or too large file is reported
+// as errInvalidAvatar.
+func (h *UserHandler) uploadAvatar(r *http.Request, uid string) (string, bool, error) {
+	file, metadata, err := r.FormFile("avatar")
+	if errors.Is(err, http.ErrMissingFile) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("%w: %w", errInvalidAvatar, err)
+	}
+	defer file.Close()
+
+	if metadata.Size > maxAvatarSize {
+		return "", false, fmt.Errorf("%w: must be at most %d bytes", errInvalidAvatar, maxAvatarSize)
+	}
+
+	if log, err := logger.LoggerFromCtx(r.Context()); err == nil {
+		log.Info("Uploading avatar",
+			zap.String("user_id", uid),
+			zap.String("filename", metadata.Filename),
+			zap.Int64("file_size", metadata.Size),
+			zap.String("content_type", metadata.Header.Get("Content-Type")))
+	}
+
+	avatar, err := h.fileStorageClient.UploadAvatar(r.Context(), uid, &FilePhoto{
+		Data:        file,
+		FileName:    metadata.Filename,
+		ContentType: metadata.Header.Get("Content-Type"),
+	})
+	if err != nil {
+		return "", false, err
+	}
+
+	return avatar, true, nil
 }
 
 // @Summary Delete user
-// @Description Удалить пользователя из системы
+// @Description Удалить аккаунт во всех сервисах: анкету, группу, заявки, фото, чаты, профиль и сессию. Сессия запроса завершается сразу, статус можно проверять, пока действует access token. Удаление идёт в фоне, повторный запрос продолжает прерванное удаление. Cookie сессии удаляются, когда удаление завершено
 // @Tags user
 // @Accept json
-// @Param uid path string true "User ID"
-// @Success 200
-// @Failure 401
-// @Failure 500
+// @Produce json
+// @Param request body DeleteUserRequest false "Refresh token of clients that do not use cookies"
+// @Success 202 {object} DeletionResponse
+// @Failure 400 {object} apierror.Error
+// @Failure 401 {object} apierror.Error
+// @Failure 500 {object} apierror.Error
 // @Router /user [delete]
 func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	log, err := logger.LoggerFromCtx(r.Context())
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Internal error")
+		return
+	}
+
+	ctx := r.Context()
+	uid, ok := ctx.Value(authorization.UID).(string)
+	if !ok || uid == "" {
+		log.Error("uid not found in context")
+		apierror.Unauthorized(w, r)
+		return
+	}
+
+	refreshToken, err := readRefreshToken(r)
+	if err != nil {
+		log.Error("Failed to decode JSON", zap.Error(err))
+		apierror.BadRequest(w, r, "Invalid JSON")
+		return
+	}
+
+	job, err := h.deletion.Start(ctx, uid, refreshToken)
+	if err != nil {
+		log.Error("Failed to start account deletion", zap.Error(err))
+
+		apierror.GRPC(w, r, err, "Failed to delete user")
+		return
+	}
+
+	if !job.Unfinished() {
+		h.cookies.ClearTokens(w)
+	}
+
+	w.Header().Set("Location", "/api/v1/user/deletion")
+	render.Status(r, http.StatusAccepted)
+	render.JSON(w, r, deletionResponse(job))
+}
+
+// @Summary Get account deletion
+// @Description Статус последнего удаления аккаунта. Когда удаление завершено, cookie сессии удаляются
+// @Tags user
+// @Produce json
+// @Success 200 {object} DeletionResponse
+// @Failure 401 {object} apierror.Error
+// @Failure 404 {object} apierror.Error
+// @Failure 500 {object} apierror.Error
+// @Router /user/deletion [get]
+func (h *UserHandler) GetDeletion(w http.ResponseWriter, r *http.Request) {
+	log, err := logger.LoggerFromCtx(r.Context())
+	if err != nil {
+		apierror.Internal(w, r, "Internal error")
 		return
 	}
 
@@ -338,16 +665,194 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	uid, ok := ctx.Value(authorization.UID).(string)
 	if !ok || uid == "" {
 		log.Error("uid not found in context")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		apierror.Unauthorized(w, r)
 		return
 	}
 
-	if err := h.userClient.DeleteUser(ctx, uid); err != nil {
-		log.Error("Failed to delete user", zap.Error(err))
+	job, err := h.deletion.Status(uid)
+	if errors.Is(err, jobs.ErrNotFound) {
+		apierror.Write(w, r, apierror.New(http.StatusNotFound, apierror.CodeNotFound, "The account is not being deleted"))
+		return
+	}
+	if err != nil {
+		log.Error("Failed to get account deletion", zap.Error(err))
 
-		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+		apierror.Internal(w, r, "Failed to get account deletion")
 		return
 	}
 
+	if !job.Unfinished() {
+		h.cookies.ClearTokens(w)
+	}
+
 	render.Status(r, http.StatusOK)
+	render.JSON(w, r, deletionResponse(job))
+}
+
+// readRefreshToken takes the refresh token from the JSON body of native
+// clients or from the cookie of browsers. It is empty if there is none.
+func readRefreshToken(r *http.Request) (string, error) {
+	if r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0 {
+		var req DeleteUserRequest
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			return "", err
+		}
+
+		return req.RefreshToken, nil
+	}
+
+	if cookie, err := r.Cookie(cookies.RefreshToken); err == nil {
+		return cookie.Value, nil
+	}
+
+	return "", nil
+}
+
+func (h *UserHandler) exportResponse(job *jobs.Job) ExportResponse {
+	resp := ExportResponse{
+		ID:        job.ID,
+		Status:    string(job.Status),
+		Steps:     jobSteps(job),
+		Error:     job.Error,
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+	}
+	if job.Status == jobs.StatusDone {
+		expiresAt := h.export.ExpiresAt(job)
+		resp.ExpiresAt = &expiresAt
+		resp.DownloadURL = "/api/v1/user/export/" + job.ID + "/download"
+	}
+
+	return resp
+}
+
+// @Summary Start data export
+// @Description Собрать все данные пользователя из сервисов в ZIP-архив. Экспорт идёт в фоне, пока он не завершён, возвращается текущий
+// @Tags user
+// @Produce json
+// @Success 202 {object} ExportResponse
+// @Failure 401 {object} apierror.Error
+// @Failure 500 {object} apierror.Error
+// @Router /user/export [post]
+func (h *UserHandler) StartExport(w http.ResponseWriter, r *http.Request) {
+	log, err := logger.LoggerFromCtx(r.Context())
+	if err != nil {
+		apierror.Internal(w, r, "Internal error")
+		return
+	}
+
+	ctx := r.Context()
+	uid, ok := ctx.Value(authorization.UID).(string)
+	if !ok || uid == "" {
+		log.Error("uid not found in context")
+		apierror.Unauthorized(w, r)
+		return
+	}
+
+	job, err := h.export.Start(ctx, uid)
+	if err != nil {
+		log.Error("Failed to start data export", zap.Error(err))
+
+		apierror.Internal(w, r, "Failed to start data export")
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/user/export/"+job.ID)
+	render.Status(r, http.StatusAccepted)
+	render.JSON(w, r, h.exportResponse(job))
+}
+
+// @Summary Get data export
+// @Description Статус экспорта данных
+// @Tags user
+// @Produce json
+// @Param id path string true "Export ID"
+// @Success 200 {object} ExportResponse
+// @Failure 401 {object} apierror.Error
+// @Failure 404 {object} apierror.Error
+// @Failure 500 {object} apierror.Error
+// @Router /user/export/{id} [get]
+func (h *UserHandler) GetExport(w http.ResponseWriter, r *http.Request) {
+	log, err := logger.LoggerFromCtx(r.Context())
+	if err != nil {
+		apierror.Internal(w, r, "Internal error")
+		return
+	}
+
+	ctx := r.Context()
+	uid, ok := ctx.Value(authorization.UID).(string)
+	if !ok || uid == "" {
+		log.Error("uid not found in context")
+		apierror.Unauthorized(w, r)
+		return
+	}
+
+	job, err := h.export.Get(uid, chi.URLParam(r, "id"))
+	if errors.Is(err, jobs.ErrNotFound) {
+		apierror.Write(w, r, apierror.New(http.StatusNotFound, apierror.CodeNotFound, "Export not found"))
+		return
+	}
+	if err != nil {
+		log.Error("Failed to get data export", zap.Error(err))
+
+		apierror.Internal(w, r, "Failed to get data export")
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, h.exportResponse(job))
+}
+
+// @Summary Download data export
+// @Description Скачать ZIP-архив завершённого экспорта
+// @Tags user
+// @Produce application/zip
+// @Param id path string true "Export ID"
+// @Success 200 {file} file
+// @Failure 401 {object} apierror.Error
+// @Failure 404 {object} apierror.Error
+// @Failure 409 {object} apierror.Error
+// @Failure 410 {object} apierror.Error
+// @Failure 500 {object} apierror.Error
+// @Router /user/export/{id}/download [get]
+func (h *UserHandler) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	log, err := logger.LoggerFromCtx(r.Context())
+	if err != nil {
+		apierror.Internal(w, r, "Internal error")
+		return
+	}
+
+	ctx := r.Context()
+	uid, ok := ctx.Value(authorization.UID).(string)
+	if !ok || uid == "" {
+		log.Error("uid not found in context")
+		apierror.Unauthorized(w, r)
+		return
+	}
+
+	file, job, err := h.export.Open(uid, chi.URLParam(r, "id"))
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		apierror.Write(w, r, apierror.New(http.StatusNotFound, apierror.CodeNotFound, "Export not found"))
+		return
+	case errors.Is(err, jobs.ErrNotFinished):
+		apierror.Write(w, r, apierror.New(http.StatusConflict, apierror.CodeConflict, "Export is not finished yet"))
+		return
+	case errors.Is(err, jobs.ErrExpired):
+		apierror.Write(w, r, apierror.New(http.StatusGone, apierror.CodeExpired, "Export has expired"))
+		return
+	case err != nil:
+		log.Error("Failed to open data export", zap.Error(err))
+
+		apierror.Internal(w, r, "Failed to open data export")
+		return
+	}
+	defer file.Close()
+
+	filename := fmt.Sprintf("export-%s.zip", job.UpdatedAt.Format("2006-01-02"))
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+	http.ServeContent(w, r, filename, job.UpdatedAt, file)
 }
@@ -0,0 +1,170 @@
+package user_handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"api-gateway/internal/ports/apierror"
+)
+
+const (
+	maxNameLength        = 50
+	maxDescriptionLength = 1000
+	maxContacts          = 10
+	maxContactLength     = 100
+)
+
+var (
+	contactPhoneRe    = regexp.MustCompile(`^\+?[0-9]{10,15}$`)
+	contactUsernameRe = regexp.MustCompile(`^@[A-Za-z0-9_]{3,32}$`)
+)
+
+// parseMergePatch reads a JSON Merge Patch (RFC 7396) of the profile into
+// an update of the present fields. null clears a field.
+func parseMergePatch(uid string, body []byte) (*UserUpdate, []apierror.FieldError, error) {
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(body, &patch); err != nil {
+		return nil, nil, err
+	}
+	if patch == nil {
+		return nil, nil, fmt.Errorf("merge patch must be a JSON object")
+	}
+
+	update := &UserUpdate{
+		User: User{ID: uid},
+	}
+
+	var fields []apierror.FieldError
+	for _, key := range slices.Sorted(maps.Keys(patch)) {
+		raw := patch[key]
+		isNull := bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+
+		var err error
+		switch key {
+		case FieldName:
+			err = decodeField(raw, isNull, &update.User.Name)
+		case FieldSurname:
+			err = decodeField(raw, isNull, &update.User.Surname)
+		case FieldDescription:
+			err = decodeField(raw, isNull, &update.User.Description)
+		case FieldContacts:
+			err = decodeField(raw, isNull, &update.User.Contacts)
+		case FieldAvatar:
+			fields = append(fields, apierror.FieldError{Field: key, Message: "use PUT or DELETE /user/avatar"})
+			continue
+		default:
+			fields = append(fields, apierror.FieldError{Field: key, Message: "unknown field"})
+			continue
+		}
+		if err != nil {
+			fields = append(fields, apierror.FieldError{Field: key, Message: "has a wrong type"})
+			continue
+		}
+
+		update.Mask = append(update.Mask, key)
+	}
+
+	return update, fields, nil
+}
+
+func decodeField[T any](raw json.RawMessage, isNull bool, dst *T) error {
+	if isNull {
+		var zero T
+		*dst = zero
+
+		return nil
+	}
+
+	return json.Unmarshal(raw, dst)
+}
+
+// validateUpdate checks the masked fields of the update and trims them.
+func validateUpdate(update *UserUpdate) []apierror.FieldError {
+	var fields []apierror.FieldError
+
+	for _, field := range update.Mask {
+		switch field {
+		case FieldName:
+			fields = append(fields, validateName(field, &update.User.Name)...)
+		case FieldSurname:
+			fields = append(fields, validateName(field, &update.User.Surname)...)
+		case FieldDescription:
+			update.User.Description = strings.TrimSpace(update.User.Description)
+			if utf8.RuneCountInString(update.User.Description) > maxDescriptionLength {
+				fields = append(fields, apierror.FieldError{Field: field, Message: fmt.Sprintf("must be at most %d characters", maxDescriptionLength)})
+			}
+		case FieldContacts:
+			fields = append(fields, validateContacts(update.User.Contacts)...)
+		}
+	}
+
+	return fields
+}
+
+func validateName(field string, name *string) []apierror.FieldError {
+	*name = strings.TrimSpace(*name)
+
+	switch length := utf8.RuneCountInString(*name); {
+	case length == 0:
+		return []apierror.FieldError{{Field: field, Message: "is required"}}
+	case length > maxNameLength:
+		return []apierror.FieldError{{Field: field, Message: fmt.Sprintf("must be at most %d characters", maxNameLength)}}
+	}
+
+	return nil
+}
+
+// validateContacts accepts e-mails, phone numbers, @usernames and http(s)
+// links.
+func validateContacts(contacts []string) []apierror.FieldError {
+	if len(contacts) > maxContacts {
+		return []apierror.FieldError{{Field: FieldContacts, Message: fmt.Sprintf("must contain at most %d contacts", maxContacts)}}
+	}
+
+	var fields []apierror.FieldError
+	for i, contact := range contacts {
+		contact = strings.TrimSpace(contact)
+		contacts[i] = contact
+
+		field := fmt.Sprintf("%s[%d]", FieldContacts, i)
+		switch {
+		case contact == "":
+			fields = append(fields, apierror.FieldError{Field: field, Message: "is empty"})
+		case utf8.RuneCountInString(contact) > maxContactLength:
+			fields = append(fields, apierror.FieldError{Field: field, Message: fmt.Sprintf("must be at most %d characters", maxContactLength)})
+		case !isContact(contact):
+			fields = append(fields, apierror.FieldError{Field: field, Message: "must be an e-mail, phone number, @username or http(s) link"})
+		}
+	}
+
+	return fields
+}
+
+func isContact(contact string) bool {
+	if contactUsernameRe.MatchString(contact) {
+		return true
+	}
+
+	phone := strings.NewReplacer(" ", "", "-", "", "(", "", ")", "").Replace(contact)
+	if contactPhoneRe.MatchString(phone) {
+		return true
+	}
+
+	if addr, err := mail.ParseAddress(contact); err == nil && addr.Address == contact {
+		return true
+	}
+
+	if u, err := url.Parse(contact); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,152 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"api-gateway/internal/ports/apierror"
+	"api-gateway/internal/ports/cookies"
+	"api-gateway/internal/ports/streams"
+
+	authorization "github.com/hesoyamTM/nbf-auth/pkg/auth"
+	"github.com/hesoyamTM/nbf-auth/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type BlockChecker interface {
+	IsUserBlocked(ctx context.Context, uid string) (bool, error)
+}
+
+type blockEntry struct {
+	blocked   bool
+	expiresAt time.Time
+}
+
+// BlockedUsers denies access to users blocked by the auth service and
+// closes their streams. Results are cached for a short TTL.
+type BlockedUsers struct {
+	client  BlockChecker
+	streams *streams.Registry
+	cookies *cookies.Issuer
+	ttl     time.Duration
+
+	mu    sync.Mutex
+	cache map[string]blockEntry
+}
+
+func NewBlockedUsers(client BlockChecker, streamRegistry *streams.Registry, cookieIssuer *cookies.Issuer, ttl time.Duration) *BlockedUsers {
+	return &BlockedUsers{
+		client:  client,
+		streams: streamRegistry,
+		cookies: cookieIssuer,
+		ttl:     ttl,
+		cache:   make(map[string]blockEntry),
+	}
+}
+
+// Middleware must run after authentication.
+func (b *BlockedUsers) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log, err := logger.LoggerFromCtx(r.Context())
+		if err != nil {
+			apierror.Internal(w, r, "Internal error")
+			return
+		}
+
+		uid, ok := r.Context().Value(authorization.UID).(string)
+		if !ok || uid == "" {
+			apierror.Unauthorized(w, r)
+			return
+		}
+
+		blocked, err := b.IsUserBlocked(r.Context(), uid)
+		if err != nil {
+			log.Error("Failed to check if user is blocked", zap.Error(err))
+			apierror.GRPC(w, r, err, "Failed to check if user is blocked")
+			return
+		}
+
+		if blocked {
+			log.Warn("Blocked user denied", zap.String("user_id", uid))
+
+			b.cookies.Clear(w, cookies.AccessToken)
+			apierror.Write(w, r, apierror.New(http.StatusForbidden, apierror.CodeUserBlocked, "User is blocked"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// IsUserBlocked returns the cached block status or asks the auth service.
+// Streams of a user are closed as soon as the user is seen as blocked.
+func (b *BlockedUsers) IsUserBlocked(ctx context.Context, uid string) (bool, error) {
+	b.mu.Lock()
+	entry, ok := b.cache[uid]
+	b.mu.Unlock()
+
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.blocked, nil
+	}
+
+	blocked, err := b.client.IsUserBlocked(ctx, uid)
+	if err != nil {
+		return false, err
+	}
+
+	b.mu.Lock()
+	b.cache[uid] = blockEntry{
+		blocked:   blocked,
+		expiresAt: time.Now().Add(b.ttl),
+	}
+	b.mu.Unlock()
+
+	if blocked {
+		b.streams.CloseUser(uid)
+	}
+
+	return blocked, nil
+}
+
+// Watch re-checks users with open streams every interval, so that a long
+// connection does not outlive the block. Expired cache entries are dropped
+// on the way.
+func (b *BlockedUsers) Watch(ctx context.Context, interval time.Duration) {
+	log, err := logger.LoggerFromCtx(ctx)
+	if err != nil {
+		panic(err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		b.prune()
+
+		for _, uid := range b.streams.Users() {
+			if _, err := b.IsUserBlocked(ctx, uid); err != nil {
+				log.Warn("Failed to re-check blocked user", zap.String("user_id", uid), zap.Error(err))
+			}
+		}
+	}
+}
+
+func (b *BlockedUsers) prune() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	for uid, entry := range b.cache {
+		if now.After(entry.expiresAt) {
+			delete(b.cache, uid)
+		}
+	}
+}
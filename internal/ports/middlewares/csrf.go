@@ -0,0 +1,124 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/ports/apierror"
+	"api-gateway/internal/ports/cookies"
+
+	"github.com/hesoyamTM/nbf-auth/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// apiPrefix is the path of the routes the browser app calls. The
+// csrf_token cookie is only issued there, not on health, metrics or docs.
+const apiPrefix = "/api/"
+
+// CSRF rejects cookie-authenticated unsafe requests that come neither from
+// a trusted origin nor echo the csrf_token cookie in the CSRF header
+// (double submit). The cookie is issued on the first API request without
+// it. Requests without an auth cookie are not checked: they can only
+// authenticate by the Authorization header, which browsers never attach on
+// their own.
+func CSRF(cfg *config.Config, cookieIssuer *cookies.Issuer) func(http.Handler) http.Handler {
+	trusted := cfg.CSRF.TrustedOrigins
+	if len(trusted) == 0 {
+		trusted = cfg.CORS.AllowedOrigins
+	}
+
+	return func(next http.Handler) http.Handler {
+		if !cfg.CSRF.IsEnabled() {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			csrfCookie, err := r.Cookie(cookies.CSRFToken)
+			if err != nil || csrfCookie.Value == "" {
+				csrfCookie = nil
+				if strings.HasPrefix(r.URL.Path, apiPrefix) {
+					if token, err := newCSRFToken(); err == nil {
+						cookieIssuer.SetReadable(w, cookies.CSRFToken, token, time.Time{})
+					}
+				}
+			}
+
+			if isSafeMethod(r.Method) || !hasAuthCookie(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if origin := requestOrigin(r); origin != "" && (slices.Contains(trusted, origin) || isSameOrigin(r, origin)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			header := r.Header.Get(cfg.CSRF.HeaderName)
+			if csrfCookie != nil && header != "" && subtle.ConstantTimeCompare([]byte(header), []byte(csrfCookie.Value)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if log, err := logger.LoggerFromCtx(r.Context()); err == nil {
+				log.Warn("CSRF check failed", zap.String("origin", r.Header.Get("Origin")), zap.String("path", r.URL.Path))
+			}
+
+			apierror.Write(w, r, apierror.New(http.StatusForbidden, apierror.CodeCSRF, "CSRF check failed"))
+		})
+	}
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+func hasAuthCookie(r *http.Request) bool {
+	for _, name := range []string{cookies.AccessToken, cookies.RefreshToken} {
+		if c, err := r.Cookie(name); err == nil && c.Value != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// requestOrigin returns Origin, or the origin of Referer if Origin is absent.
+func requestOrigin(r *http.Request) string {
+	if origin := r.Header.Get("Origin"); origin != "" && origin != "null" {
+		return origin
+	}
+
+	referer, err := url.Parse(r.Referer())
+	if err != nil || referer.Host == "" {
+		return ""
+	}
+
+	return referer.Scheme + "://" + referer.Host
+}
+
+func isSameOrigin(r *http.Request, origin string) bool {
+	u, err := url.Parse(origin)
+
+	return err == nil && u.Host == r.Host
+}
+
+func newCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
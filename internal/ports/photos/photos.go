@@ -0,0 +1,285 @@
+// Package photos resolves storage photo IDs to presigned URLs for the
+// handlers. URLs are resolved in parallel and cached until shortly before
+// they expire. A photo that cannot be resolved is replaced by a typed
+// placeholder, so clients never receive a raw storage ID.
+package photos
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/metrics"
+	"api-gateway/internal/ports/apierror"
+	"api-gateway/internal/tracing"
+
+	"github.com/hesoyamTM/nbf-auth/pkg/logger"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Placeholders returned instead of a URL.
+const (
+	// PlaceholderUnavailable: the storage is down, retrying later may help.
+	PlaceholderUnavailable = "placeholder:unavailable"
+	// PlaceholderNotFound: the photo does not exist anymore.
+	PlaceholderNotFound = "placeholder:not_found"
+	// PlaceholderError: any other failure.
+	PlaceholderError = "placeholder:error"
+)
+
+type URLGetter interface {
+	GetPhotoURL(ctx context.Context, ownerID string, photoID string) (string, error)
+}
+
+type request struct {
+	ownerID string
+	photoID string
+	dest    *string
+}
+
+// Batch collects the photo fields of a response. Each field holds a photo
+// ID and is overwritten with the URL or a placeholder by Resolve.
+type Batch struct {
+	requests []request
+}
+
+// Add adds a single field, empty IDs are skipped.
+func (b *Batch) Add(ownerID string, dest *string) {
+	if *dest == "" {
+		return
+	}
+
+	b.requests = append(b.requests, request{
+		ownerID: ownerID,
+		photoID: *dest,
+		dest:    dest,
+	})
+}
+
+// AddAll adds every element of a slice of photo IDs.
+func (b *Batch) AddAll(ownerID string, photoIDs []string) {
+	for i := range photoIDs {
+		b.Add(ownerID, &photoIDs[i])
+	}
+}
+
+func (b *Batch) Len() int {
+	return len(b.requests)
+}
+
+type cacheEntry struct {
+	url       string
+	expiresAt time.Time
+}
+
+type Resolver struct {
+	client URLGetter
+	cfg    config.Photos
+
+	group singleflight.Group
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+func NewResolver(client URLGetter, cfg config.Photos) *Resolver {
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = 1
+	}
+
+	return &Resolver{
+		client: client,
+		cfg:    cfg,
+		cache:  make(map[string]cacheEntry),
+	}
+}
+
+// Resolve fills every field of the batch. operation names the handler in
+// traces and metrics.
+func (r *Resolver) Resolve(ctx context.Context, operation string, batch *Batch) {
+	if batch.Len() == 0 {
+		return
+	}
+
+	ctx, span := tracing.StartSpan(ctx, operation+".ResolvePhotoURLs",
+		trace.WithAttributes(attribute.Int("photos.items", batch.Len())),
+	)
+	defer span.End()
+
+	sem := make(chan struct{}, r.cfg.MaxConcurrent)
+	var wg sync.WaitGroup
+
+	for _, req := range batch.requests {
+		if u, ok := r.cached(req.ownerID, req.photoID); ok {
+			*req.dest = u
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			*req.dest = r.resolve(ctx, operation, req.ownerID, req.photoID)
+		}()
+	}
+
+	wg.Wait()
+}
+
+func (r *Resolver) resolve(ctx context.Context, operation, ownerID, photoID string) string {
+	ch := r.group.DoChan(cacheKey(ownerID, photoID), func() (any, error) {
+		// the call is shared, one canceled request must not fail the others
+		callCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.cfg.Timeout)
+		defer cancel()
+
+		u, err := r.client.GetPhotoURL(callCtx, ownerID, photoID)
+		if err != nil {
+			return "", err
+		}
+
+		r.store(ownerID, photoID, u)
+
+		return u, nil
+	})
+
+	var err error
+	select {
+	case res := <-ch:
+		if res.Err == nil {
+			return res.Val.(string)
+		}
+		err = res.Err
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	metrics.PresignedURLFailed(operation)
+	if log, logErr := logger.LoggerFromCtx(ctx); logErr == nil {
+		log.Warn("Failed to get presigned URL",
+			zap.String("operation", operation),
+			zap.String("owner_id", ownerID),
+			zap.String("photo_id", photoID),
+			zap.Error(err))
+	}
+
+	return placeholder(err)
+}
+
+func placeholder(err error) string {
+	if _, open := apierror.RetryAfter(err); open {
+		return PlaceholderUnavailable
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return PlaceholderUnavailable
+	}
+
+	switch status.Code(err) {
+	case codes.NotFound:
+		return PlaceholderNotFound
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted:
+		return PlaceholderUnavailable
+	default:
+		return PlaceholderError
+	}
+}
+
+func cacheKey(ownerID, photoID string) string {
+	return ownerID + "/" + photoID
+}
+
+func (r *Resolver) cached(ownerID, photoID string) (string, bool) {
+	r.mu.Lock()
+	entry, ok := r.cache[cacheKey(ownerID, photoID)]
+	r.mu.Unlock()
+
+	hit := ok && time.Now().Before(entry.expiresAt)
+	metrics.PhotoURLCacheLookup(hit)
+
+	return entry.url, hit
+}
+
+func (r *Resolver) store(ownerID, photoID, u string) {
+	now := time.Now()
+
+	expiresAt := now.Add(r.cfg.CacheTTL)
+	if presignedExpiry, ok := expiry(u); ok {
+		expiresAt = presignedExpiry.Add(-r.cfg.ExpiryMargin)
+	}
+	if !expiresAt.After(now) {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.cache) >= r.cfg.CacheSize {
+		for key, entry := range r.cache {
+			if now.After(entry.expiresAt) {
+				delete(r.cache, key)
+			}
+		}
+	}
+
+	// still full: drop random entries, they are cheap to resolve again
+	for key := range r.cache {
+		if len(r.cache) < r.cfg.CacheSize {
+			break
+		}
+		delete(r.cache, key)
+	}
+
+	r.cache[cacheKey(ownerID, photoID)] = cacheEntry{
+		url:       u,
+		expiresAt: expiresAt,
+	}
+}
+
+// expiry reads the expiration of an S3 presigned URL: X-Amz-Date plus
+// X-Amz-Expires for SigV4, Expires for SigV2.
+func expiry(rawURL string) (time.Time, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	query := u.Query()
+
+	if date, expires := query.Get("X-Amz-Date"), query.Get("X-Amz-Expires"); date != "" && expires != "" {
+		signedAt, err := time.Parse("20060102T150405Z", date)
+		if err != nil {
+			return time.Time{}, false
+		}
+
+		seconds, err := strconv.Atoi(expires)
+		if err != nil {
+			return time.Time{}, false
+		}
+
+		return signedAt.Add(time.Duration(seconds) * time.Second), true
+	}
+
+	if expires := query.Get("Expires"); expires != "" {
+		unix, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+
+		return time.Unix(unix, 0), true
+	}
+
+	return time.Time{}, false
+}
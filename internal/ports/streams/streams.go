@@ -0,0 +1,162 @@
+// Package streams keeps track of long-lived SSE and WebSocket connections
+// so that they can be drained on shutdown or closed for a single user.
+package streams
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"api-gateway/internal/metrics"
+
+	authorization "github.com/hesoyamTM/nbf-auth/pkg/auth"
+)
+
+type Kind string
+
+const (
+	KindSSE       Kind = "sse"
+	KindWebSocket Kind = "websocket"
+)
+
+type stream struct {
+	kind   Kind
+	uid    string
+	cancel context.CancelFunc
+}
+
+type Registry struct {
+	mu      sync.Mutex
+	nextID  uint64
+	streams map[uint64]*stream
+	wg      sync.WaitGroup
+
+	draining  chan struct{}
+	drainOnce sync.Once
+
+	retryHint time.Duration
+}
+
+// NewRegistry creates a registry. retryHint is sent to SSE clients as the
+// reconnect delay when the server is draining.
+func NewRegistry(retryHint time.Duration) *Registry {
+	return &Registry{
+		streams:   make(map[uint64]*stream),
+		draining:  make(chan struct{}),
+		retryHint: retryHint,
+	}
+}
+
+// Track registers a long-lived connection of the authenticated user in ctx.
+// The returned context is cancelled when the connection is force-closed,
+// release must be called when the handler returns.
+func (r *Registry) Track(ctx context.Context, kind Kind) (context.Context, func()) {
+	uid, _ := ctx.Value(authorization.UID).(string)
+	ctx, cancel := context.WithCancel(ctx)
+
+	r.mu.Lock()
+	id := r.nextID
+	r.nextID++
+	r.streams[id] = &stream{
+		kind:   kind,
+		uid:    uid,
+		cancel: cancel,
+	}
+	r.wg.Add(1)
+	r.mu.Unlock()
+
+	metrics.StreamOpened(string(kind))
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			r.mu.Lock()
+			delete(r.streams, id)
+			r.mu.Unlock()
+
+			cancel()
+			metrics.StreamClosed(string(kind))
+			r.wg.Done()
+		})
+	}
+
+	return ctx, release
+}
+
+// Draining is closed when the server starts shutting down. Handlers should
+// say goodbye to their clients and return.
+func (r *Registry) Draining() <-chan struct{} {
+	return r.draining
+}
+
+// RetryHint is the reconnect delay suggested to clients on shutdown.
+func (r *Registry) RetryHint() time.Duration {
+	return r.retryHint
+}
+
+func (r *Registry) Drain() {
+	r.drainOnce.Do(func() {
+		close(r.draining)
+	})
+}
+
+// Wait blocks until all tracked connections are released or ctx is done.
+func (r *Registry) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CloseAll force-closes every tracked connection.
+func (r *Registry) CloseAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, s := range r.streams {
+		s.cancel()
+	}
+}
+
+// CloseUser force-closes every tracked connection of the user and returns
+// how many there were.
+func (r *Registry) CloseUser(uid string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	closed := 0
+	for _, s := range r.streams {
+		if s.uid != "" && s.uid == uid {
+			s.cancel()
+			closed++
+		}
+	}
+
+	return closed
+}
+
+// Users returns the users that have open connections.
+func (r *Registry) Users() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seen := make(map[string]struct{})
+	users := make([]string, 0, len(r.streams))
+	for _, s := range r.streams {
+		if _, ok := seen[s.uid]; ok || s.uid == "" {
+			continue
+		}
+		seen[s.uid] = struct{}{}
+		users = append(users, s.uid)
+	}
+
+	return users
+}
@@ -0,0 +1,49 @@
+// Package requestinfo carries details of the incoming HTTP request that are
+// forwarded to backend services.
+package requestinfo
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+// IdempotencyKeyHeader lets clients mark a write as safe to retry.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+type ctxKey struct{}
+
+type Info struct {
+	ClientIP       string
+	UserAgent      string
+	IdempotencyKey string
+}
+
+func With(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, ctxKey{}, info)
+}
+
+func From(ctx context.Context) (Info, bool) {
+	info, ok := ctx.Value(ctxKey{}).(Info)
+
+	return info, ok
+}
+
+// Middleware stores the client IP, user-agent and idempotency key of the
+// request in its context.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+
+		ctx := With(r.Context(), Info{
+			ClientIP:       ip,
+			UserAgent:      r.UserAgent(),
+			IdempotencyKey: r.Header.Get(IdempotencyKeyHeader),
+		})
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,213 @@
+// Package tlsreload keeps TLS certificates and CA bundles loaded from disk
+// up to date, so that rotated files are picked up without a restart.
+package tlsreload
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hesoyamTM/nbf-auth/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Reloader holds an optional certificate/key pair and an optional CA bundle.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+	modTime time.Time
+}
+
+// New loads the files once. Empty paths are skipped.
+func New(certFile, keyFile, caFile string) (*Reloader, error) {
+	const op = "tlsreload.New"
+
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+	}
+
+	if err := r.Reload(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return r, nil
+}
+
+// Reload reads all files again. The previous certificates are kept on error.
+func (r *Reloader) Reload() error {
+	const op = "tlsreload.Reload"
+
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var cert *tls.Certificate
+	if r.certFile != "" {
+		pair, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		cert = &pair
+	}
+
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("%s: no certificates in %s", op, r.caFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = cert
+	r.pool = pool
+	r.modTime = modTime
+	r.mu.Unlock()
+
+	return nil
+}
+
+// Watch checks the files every interval and reloads them when any of them
+// has changed, until ctx is done.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	log, err := logger.LoggerFromCtx(ctx)
+	if err != nil {
+		panic(err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		modTime, err := r.latestModTime()
+		if err != nil {
+			log.Warn("failed to stat certificate files", zap.Error(err))
+			continue
+		}
+
+		r.mu.RLock()
+		changed := modTime.After(r.modTime)
+		r.mu.RUnlock()
+		if !changed {
+			continue
+		}
+
+		if err := r.Reload(); err != nil {
+			log.Error("failed to reload certificates", zap.Error(err))
+			continue
+		}
+
+		log.Info("certificates reloaded",
+			zap.String("cert_file", r.certFile),
+			zap.String("ca_file", r.caFile))
+	}
+}
+
+// GetCertificate is meant for tls.Config.GetCertificate of servers.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.certificate()
+}
+
+// GetClientCertificate is meant for tls.Config.GetClientCertificate of clients.
+func (r *Reloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	cert, err := r.certificate()
+	if err != nil {
+		// no certificate is sent, the server decides whether it needs one
+		return &tls.Certificate{}, nil
+	}
+
+	return cert, nil
+}
+
+// ClientConfig returns a client TLS config that verifies the server against
+// the current CA bundle (or the system roots without one) and presents the
+// current client certificate if there is one.
+func (r *Reloader) ClientConfig(serverName string) *tls.Config {
+	return &tls.Config{
+		MinVersion:           tls.VersionTLS12,
+		ServerName:           serverName,
+		GetClientCertificate: r.GetClientCertificate,
+		// verification is done in VerifyConnection, because RootCAs cannot
+		// be swapped after the config is handed over
+		InsecureSkipVerify: true,
+		VerifyConnection:   r.verifyConnection,
+	}
+}
+
+func (r *Reloader) verifyConnection(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("tlsreload: no server certificate")
+	}
+
+	r.mu.RLock()
+	pool := r.pool
+	r.mu.RUnlock()
+
+	opts := x509.VerifyOptions{
+		Roots:         pool,
+		DNSName:       cs.ServerName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+
+	_, err := cs.PeerCertificates[0].Verify(opts)
+
+	return err
+}
+
+func (r *Reloader) certificate() (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.cert == nil {
+		return nil, errors.New("tlsreload: no certificate configured")
+	}
+
+	return r.cert, nil
+}
+
+func (r *Reloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+
+	for _, file := range []string{r.certFile, r.keyFile, r.caFile} {
+		if file == "" {
+			continue
+		}
+
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}